@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SearchAnalytics records searches and the results chosen from them. It is
+// nil, recording nothing, until main opens the log named by SEARCH_LOG.
+var SearchAnalytics *SearchLog
+
+// searchEvent is one line of the search log
+type searchEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`          // "search" or "click"
+	Query string    `json:"query"`          // As normalized by normalizeQuery
+	Hits  int       `json:"hits,omitempty"` // Matches of a search
+	Type  string    `json:"type,omitempty"` // Result type of a click
+	ID    int       `json:"id,omitempty"`   // Artist of a click, if any
+}
+
+// queryCounts is what the log tells about one query
+type queryCounts struct {
+	searches int
+	hits     int // Matches the last time it was searched
+	clicks   int
+}
+
+// SearchLog appends search events to a file of JSON lines and keeps their
+// counts per query. Opening a log replays the file, so counts survive
+// restarts. It is safe for concurrent use.
+type SearchLog struct {
+	mu      sync.Mutex
+	file    *os.File
+	queries map[string]*queryCounts
+}
+
+// OpenSearchLog opens the search log at path, creating it if needed. Lines
+// that do not replay, such as one torn by a crash while it was written, are
+// logged and skipped: losing an event is better than not starting.
+func OpenSearchLog(path string) (*SearchLog, error) {
+	l := &SearchLog{queries: make(map[string]*queryCounts)}
+
+	torn := false // the file does not end with a newline
+	existing, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		defer existing.Close()
+		// a Reader rather than a Scanner, so no line is ever too long to skip
+		reader := bufio.NewReader(existing)
+		for line := 1; ; line++ {
+			text, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			torn = len(text) > 0 && text[len(text)-1] != '\n'
+			if len(bytes.TrimSpace(text)) > 0 {
+				var event searchEvent
+				if jsonErr := json.Unmarshal(text, &event); jsonErr != nil {
+					log.Printf("Search log %s:%d skipped: %v", path, line, jsonErr)
+				} else {
+					event.Query = normalizeQuery(event.Query)
+					l.count(event)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+		}
+	}
+
+	l.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if torn {
+		// end the torn line, or the next event would be appended to it
+		if _, err := l.file.Write([]byte{'\n'}); err != nil {
+			l.file.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Close closes the file of the log
+func (l *SearchLog) Close() error {
+	return l.file.Close()
+}
+
+// count adds event to the counts of its query. l.mu must be held.
+func (l *SearchLog) count(event searchEvent) {
+	counts, ok := l.queries[event.Query]
+	if !ok {
+		counts = &queryCounts{}
+		l.queries[event.Query] = counts
+	}
+	switch event.Event {
+	case "search":
+		counts.searches++
+		counts.hits = event.Hits
+	case "click":
+		counts.clicks++
+	}
+}
+
+// record appends event to the log and counts it. Failing to write is only
+// logged: analytics never fail a search.
+func (l *SearchLog) record(event searchEvent) {
+	if l == nil || event.Query == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event.Time = time.Now().UTC()
+	line, err := json.Marshal(event)
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Println("Search log error:", err)
+		return
+	}
+	l.count(event)
+}
+
+// RecordSearch logs a search for query that found hits matches
+func (l *SearchLog) RecordSearch(query string, hits int) {
+	l.record(searchEvent{Event: "search", Query: normalizeQuery(query), Hits: hits})
+}
+
+// RecordClick logs that a result of resultType, about the artist id if not
+// zero, was chosen from the results of query
+func (l *SearchLog) RecordClick(query, resultType string, id int) {
+	l.record(searchEvent{Event: "click", Query: normalizeQuery(query), Type: resultType, ID: id})
+}
+
+// maxQueryLen is how many runes of a query are logged. Longer queries are
+// cut, keeping the log made of short lines.
+const maxQueryLen = 200
+
+// normalizeQuery is the form queries are logged and counted under: lower
+// case with single spaces, cut to maxQueryLen runes
+func normalizeQuery(query string) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if runes := []rune(query); len(runes) > maxQueryLen {
+		query = strings.TrimSpace(string(runes[:maxQueryLen]))
+	}
+	return query
+}
+
+// QueryStats describe how one query was used
+type QueryStats struct {
+	Query        string  `json:"query"`
+	Searches     int     `json:"searches"`
+	Hits         int     `json:"hits"` // Matches the last time it was searched
+	Clicks       int     `json:"clicks"`
+	ClickThrough float64 `json:"clickThrough"` // Clicks per search
+}
+
+// SearchStats summarize a search log
+type SearchStats struct {
+	Searches     int          `json:"searches"`
+	Clicks       int          `json:"clicks"`
+	ClickThrough float64      `json:"clickThrough"`
+	TopQueries   []QueryStats `json:"topQueries"`  // Most searched first
+	ZeroResults  []QueryStats `json:"zeroResults"` // Queries that last found nothing, most searched first
+}
+
+// statsLimit is how many queries each list of SearchStats holds
+const statsLimit = 20
+
+// clickThrough returns clicks per search, or zero without searches
+func clickThrough(clicks, searches int) float64 {
+	if searches == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(searches)
+}
+
+// Stats summarizes the log. A nil log has no searches.
+func (l *SearchLog) Stats() SearchStats {
+	stats := SearchStats{TopQueries: []QueryStats{}, ZeroResults: []QueryStats{}}
+	if l == nil {
+		return stats
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var searched []QueryStats
+	for query, counts := range l.queries {
+		stats.Searches += counts.searches
+		stats.Clicks += counts.clicks
+		if counts.searches == 0 {
+			continue // clicked, but searched before the log was started
+		}
+		searched = append(searched, QueryStats{
+			Query:        query,
+			Searches:     counts.searches,
+			Hits:         counts.hits,
+			Clicks:       counts.clicks,
+			ClickThrough: clickThrough(counts.clicks, counts.searches),
+		})
+	}
+	stats.ClickThrough = clickThrough(stats.Clicks, stats.Searches)
+
+	sort.Slice(searched, func(i, j int) bool {
+		if searched[i].Searches != searched[j].Searches {
+			return searched[i].Searches > searched[j].Searches
+		}
+		return searched[i].Query < searched[j].Query
+	})
+	for _, q := range searched {
+		if len(stats.TopQueries) < statsLimit {
+			stats.TopQueries = append(stats.TopQueries, q)
+		}
+		if q.Hits == 0 && len(stats.ZeroResults) < statsLimit {
+			stats.ZeroResults = append(stats.ZeroResults, q)
+		}
+	}
+	return stats
+}
+
+// SearchClickHandler records the result chosen from a search. It takes a
+// POST form with the query q, the result type and, for results about an
+// artist, its id, as sent by navigator.sendBeacon.
+func SearchClickHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		wrongMethodHandler(w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		badRequestHandler(w)
+		return
+	}
+
+	query, resultType := r.PostForm.Get("q"), r.PostForm.Get("type")
+	id := 0
+	if value := r.PostForm.Get("id"); value != "" {
+		var err error
+		if id, err = strconv.Atoi(value); err != nil || id < 0 {
+			badRequestHandler(w)
+			return
+		}
+	}
+	known := false
+	for _, t := range resultTypes {
+		known = known || t == resultType
+	}
+	if strings.TrimSpace(query) == "" || !known {
+		badRequestHandler(w)
+		return
+	}
+
+	SearchAnalytics.RecordClick(query, resultType, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchStatsHandler shows the top queries, the queries finding nothing and
+// click-through rates from SearchAnalytics, as JSON with format=json
+func SearchStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wrongMethodHandler(w)
+		return
+	}
+
+	stats := SearchAnalytics.Stats()
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+		return
+	}
+
+	// Check if the handler is running in "test mode" to skip template rendering
+	if os.Getenv("TEST_MODE") == "true" {
+		fmt.Fprintln(w, "Mocked template rendering with searches:", stats.Searches)
+		for _, q := range stats.TopQueries {
+			fmt.Fprintln(w, "top", q.Query, q.Searches)
+		}
+		for _, q := range stats.ZeroResults {
+			fmt.Fprintln(w, "zero", q.Query, q.Searches)
+		}
+		return
+	}
+
+	tmpl, err := template.New("searchStats.html").
+		Funcs(template.FuncMap{"percent": func(rate float64) string { return fmt.Sprintf("%.0f%%", rate*100) }}).
+		ParseFiles("templates/searchStats.html")
+	if err != nil {
+		InternalServerHandler(w)
+		log.Println("Template parsing error: ", err)
+		return
+	}
+	if err := tmpl.Execute(w, stats); err != nil {
+		log.Println("Template execution error: ", err)
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useSearchLog swaps SearchAnalytics for a log in a temporary directory for
+// the duration of the test and returns its path
+func useSearchLog(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "search.log")
+	l, err := OpenSearchLog(path)
+	if err != nil {
+		t.Fatalf("OpenSearchLog: %v", err)
+	}
+	original := SearchAnalytics
+	SearchAnalytics = l
+	t.Cleanup(func() {
+		SearchAnalytics = original
+		l.Close()
+	})
+	return path
+}
+
+func TestSearchLog(t *testing.T) {
+	path := useSearchLog(t)
+	SearchAnalytics.RecordSearch("Queen", 3)
+	SearchAnalytics.RecordSearch("  queen ", 2)
+	SearchAnalytics.RecordSearch("nirvana", 0)
+	SearchAnalytics.RecordClick("QUEEN", "artist", 1)
+	SearchAnalytics.RecordClick("pink", "artist", 5) // searched before the log was started
+
+	want := SearchStats{
+		Searches:     3,
+		Clicks:       2,
+		ClickThrough: 2.0 / 3,
+		TopQueries: []QueryStats{
+			{Query: "queen", Searches: 2, Hits: 2, Clicks: 1, ClickThrough: 0.5},
+			{Query: "nirvana", Searches: 1, Hits: 0},
+		},
+		ZeroResults: []QueryStats{{Query: "nirvana", Searches: 1, Hits: 0}},
+	}
+	if got := SearchAnalytics.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// the log is append-only and counts survive reopening it
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(body), "\n"); lines != 5 {
+		t.Errorf("log has %d lines, want 5:\n%s", lines, body)
+	}
+	reopened, err := OpenSearchLog(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer reopened.Close()
+	if got := reopened.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() after reopening = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchLogCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.log")
+	long := strings.Repeat("queen ", 20000) // longer than a Scanner's default line
+	lines := []string{
+		`{"event":"search","query":"a","hits":1}`,
+		"not json",
+		`{"event":"search","query":"` + long + `","hits":2}`,
+		`{"event":"search","query":"b","hits":3}`,
+		`{"event":"sea`, // torn by a crash
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+
+	l, err := OpenSearchLog(path)
+	if err != nil {
+		t.Fatalf("OpenSearchLog() error = %v", err)
+	}
+	l.RecordSearch("c", 4)
+	l.RecordSearch(long, 5)
+	l.Close()
+
+	// bad lines are skipped, long queries cut, and the torn line is ended
+	// before the next event so that it replays
+	reopened, err := OpenSearchLog(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer reopened.Close()
+	stats := reopened.Stats()
+	if stats.Searches != 5 || len(stats.TopQueries) != 4 {
+		t.Fatalf("Stats() = %+v, want 5 searches of 4 queries", stats)
+	}
+	if top := stats.TopQueries[0]; top.Searches != 2 || len([]rune(top.Query)) > maxQueryLen {
+		t.Errorf("long query counted as %d searches of %q", top.Searches, top.Query)
+	}
+	body, _ := os.ReadFile(path)
+	if lines := strings.Split(string(body), "\n"); len(lines) != 8 || len(lines[6]) > 2*maxQueryLen {
+		t.Errorf("log has %d lines, the last written %d bytes long", len(lines), len(lines[6]))
+	}
+}
+
+func TestSearchAnalyticsHandlers(t *testing.T) {
+	useFixtureCatalog(t)
+	useSearchLog(t)
+	original := SearchCache
+	SearchCache = NewResponseCache(8)
+	t.Cleanup(func() { SearchCache = original })
+
+	searchResponse(t, "q=queen")
+	searchResponse(t, "q=queen&offset=1") // another page of the same search
+	searchResponse(t, "q=zzzzzz")
+
+	// the search box: completions, or the streamed search when there are none
+	SuggestHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/suggest?q=scorp", nil))
+	SuggestHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/suggest?q=skorpions", nil))
+	streamSearch(t, "q=skorpions")
+
+	click := func(form url.Values) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/search/click", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		SearchClickHandler(w, r)
+		return w.Code
+	}
+	if code := click(url.Values{"q": {"Queen"}, "type": {"artist"}, "id": {"1"}}); code != http.StatusNoContent {
+		t.Errorf("click = %d", code)
+	}
+	for _, form := range []url.Values{
+		{"q": {"queen"}, "type": {"song"}},
+		{"q": {""}, "type": {"artist"}},
+		{"q": {"queen"}, "type": {"artist"}, "id": {"x"}},
+	} {
+		if code := click(form); code != http.StatusBadRequest {
+			t.Errorf("click %v = %d, want 400", form, code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	SearchStatsHandler(w, httptest.NewRequest(http.MethodGet, "/admin/search-stats?format=json", nil))
+	var stats SearchStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("decoding stats: %v", err)
+	}
+	if stats.Searches != 4 || stats.Clicks != 1 || len(stats.TopQueries) != 4 || stats.TopQueries[0].Query != "queen" {
+		t.Errorf("stats = %+v", stats)
+	}
+	for _, q := range stats.TopQueries {
+		if q.Query == "skorpions" && (q.Searches != 1 || q.Hits == 0) || q.Query == "scorp" && q.Hits == 0 {
+			t.Errorf("search box query %+v", q)
+		}
+	}
+	if len(stats.ZeroResults) != 1 || stats.ZeroResults[0].Query != "zzzzzz" {
+		t.Errorf("zero results = %+v", stats.ZeroResults)
+	}
+
+	t.Setenv("TEST_MODE", "true")
+	w = httptest.NewRecorder()
+	SearchStatsHandler(w, httptest.NewRequest(http.MethodGet, "/admin/search-stats", nil))
+	if body := w.Body.String(); !strings.Contains(body, "top queen 1") || !strings.Contains(body, "zero zzzzzz 1") {
+		t.Errorf("stats page = %q", body)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tracker/src"
+)
+
+// defaultSearchCacheSize is how many searches SearchCache remembers unless
+// main sets SEARCH_CACHE_SIZE
+const defaultSearchCacheSize = 256
+
+// SearchCache remembers the answers of recent searches for /search and
+// /results. main replaces it with one of the configured size.
+var SearchCache = NewResponseCache(defaultSearchCacheSize)
+
+// ResponseCache is a least recently used cache of search answers: every
+// result of a query before paging, with its suggestions and facets. Answers
+// belong to the catalog snapshot they were computed from, and the cache
+// empties itself when a search runs against a new one. It is safe for
+// concurrent use.
+type ResponseCache struct {
+	mu       sync.Mutex
+	capacity int
+	snapshot *src.Snapshot
+	entries  map[string]*list.Element
+	order    *list.List // of *cacheEntry, most recently used at the front
+	hits     uint64
+	misses   uint64
+}
+
+type cacheEntry struct {
+	key  string
+	resp SearchResponse
+}
+
+// CacheStats describe a ResponseCache
+type CacheStats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Entries  int    `json:"entries"`
+	Capacity int    `json:"capacity"`
+	Version  uint64 `json:"version"` // Snapshot the cached answers belong to
+}
+
+// NewResponseCache returns a cache holding up to capacity answers. With a
+// capacity of zero nothing is cached, but misses are still counted.
+func NewResponseCache(capacity int) *ResponseCache {
+	return &ResponseCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// sync drops every answer when snap is not the snapshot they came from.
+// c.mu must be held.
+func (c *ResponseCache) sync(snap *src.Snapshot) {
+	if c.snapshot != snap {
+		c.snapshot = snap
+		c.entries = make(map[string]*list.Element)
+		c.order.Init()
+	}
+}
+
+// Get returns the answer cached for key in snap, counting a hit or a miss
+func (c *ResponseCache) Get(snap *src.Snapshot, key string) (SearchResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sync(snap)
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return SearchResponse{}, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).resp, true
+}
+
+// Put caches the answer for key in snap, evicting the least recently used
+// answer when full. The cache keeps resp as it is: callers must not modify
+// it afterwards.
+func (c *ResponseCache) Put(snap *src.Snapshot, key string, resp SearchResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sync(snap)
+	if c.capacity <= 0 {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).resp = resp
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, resp})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Stats returns the counters of the cache
+func (c *ResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len(), Capacity: c.capacity}
+	if c.snapshot != nil {
+		stats.Version = c.snapshot.Version
+	}
+	return stats
+}
+
+// cacheKey identifies the answer to query under the filters of params.
+// Spacing never matters, and case only matters to the operators of the
+// query language.
+func cacheKey(query string, params searchParams) string {
+	if node, err := parseQuery(query); params.mode != "" || err == nil && node != nil && isPlainText(node) {
+		query = strings.ToLower(query)
+	}
+
+	var types []string
+	for t := range params.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	return params.mode + "\x00" + strings.Join(types, ",") + "\x00" + strings.Join(strings.Fields(query), " ")
+}
+
+// cachedSearch answers query over snap with every ranked result before
+// paging, its suggestions and facets, from SearchCache when it can. hit
+// reports whether it could. When ctx ends before every category is searched
+// the answer is partial and is not cached.
+func cachedSearch(ctx context.Context, snap *src.Snapshot, query string, params searchParams) (resp SearchResponse, hit bool, err error) {
+	// answers found through older aliases are never served again
+	scope := newSearchScope(snap)
+	key := strconv.FormatUint(scope.generation, 10) + "\x00" + cacheKey(query, params)
+	if resp, ok := SearchCache.Get(snap, key); ok {
+		return resp, true, nil
+	}
+
+	// ranked across every type first, so the type facet can offer the
+	// types the parameters leave out
+	anyType := params
+	anyType.types = nil
+	all, err := rankedResults(ctx, scope, query, anyType)
+	if err != nil {
+		return SearchResponse{}, false, err
+	}
+	results := filterTypes(all, params.types)
+	facets := facetResults(snap, results)
+	facets.Type = typeFacet(all)
+
+	resp = SearchResponse{
+		Success:     true,
+		Results:     results,
+		Total:       len(results),
+		Suggestions: didYouMean(snap, query, len(results)),
+		Facets:      facets,
+		Partial:     ctx.Err() != nil,
+	}
+	if !resp.Partial {
+		SearchCache.Put(snap, key, resp)
+	}
+	return resp, false, nil
+}
+
+// SearchCacheHandler reports the counters of SearchCache as JSON
+func SearchCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wrongMethodHandler(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SearchCache.Stats())
+}
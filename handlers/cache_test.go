@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestResponseCache(t *testing.T) {
+	useFixtureCatalog(t)
+	snap := Catalog.Snapshot()
+	cache := NewResponseCache(2)
+
+	cache.Put(snap, "a", SearchResponse{Total: 1})
+	cache.Put(snap, "b", SearchResponse{Total: 2})
+	if resp, ok := cache.Get(snap, "a"); !ok || resp.Total != 1 {
+		t.Fatalf("Get(a) = %+v, %v", resp, ok)
+	}
+	cache.Put(snap, "c", SearchResponse{Total: 3}) // evicts b, used least recently
+	if _, ok := cache.Get(snap, "b"); ok {
+		t.Errorf("b was not evicted")
+	}
+	if _, ok := cache.Get(snap, "c"); !ok {
+		t.Errorf("c is missing")
+	}
+
+	want := CacheStats{Hits: 2, Misses: 1, Entries: 2, Capacity: 2, Version: snap.Version}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// a new snapshot invalidates everything
+	if err := Catalog.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get(Catalog.Snapshot(), "a"); ok {
+		t.Errorf("answer survived a reload")
+	}
+	if got := cache.Stats(); got.Entries != 0 || got.Version != snap.Version+1 {
+		t.Errorf("Stats() after reload = %+v", got)
+	}
+
+	disabled := NewResponseCache(0)
+	disabled.Put(snap, "a", SearchResponse{})
+	if _, ok := disabled.Get(snap, "a"); ok || disabled.Stats().Misses != 1 {
+		t.Errorf("a cache of size 0 cached: %+v", disabled.Stats())
+	}
+}
+
+func Test_cacheKey(t *testing.T) {
+	key := func(query string, rawTypes string) string {
+		values := map[string][]string{}
+		if rawTypes != "" {
+			values["type"] = []string{rawTypes}
+		}
+		params, err := parseSearchParams(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cacheKey(query, params)
+	}
+
+	same := [][2]string{
+		{"Pink Floyd", "  pink   floyd "},
+		{"member:Flea AND year:1980s", "member:Flea  AND year:1980s"},
+	}
+	for _, pair := range same {
+		if key(pair[0], "") != key(pair[1], "") {
+			t.Errorf("%q and %q have different keys", pair[0], pair[1])
+		}
+	}
+	if key("a AND b", "") == key("a and b", "") {
+		t.Errorf("operators and plain words share a key")
+	}
+	if key("queen", "artist,member") != key("queen", "member,artist") {
+		t.Errorf("type order changes the key")
+	}
+	if key("queen", "artist") == key("queen", "") {
+		t.Errorf("type filter does not change the key")
+	}
+}
+
+func TestSearchHandlerCache(t *testing.T) {
+	useFixtureCatalog(t)
+	original := SearchCache
+	SearchCache = NewResponseCache(8)
+	t.Cleanup(func() { SearchCache = original })
+
+	get := func(rawQuery string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		SearchHandler(w, httptest.NewRequest(http.MethodGet, "/search?"+rawQuery, nil))
+		return w
+	}
+
+	first := get("q=USA&limit=2")
+	second := get("q=usa&limit=2&offset=2") // same search, another page
+	if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("X-Cache = %q then %q", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+	}
+
+	var a, b SearchResponse
+	json.NewDecoder(first.Body).Decode(&a)
+	json.NewDecoder(second.Body).Decode(&b)
+	if a.Total != b.Total || len(a.Results) != 2 || reflect.DeepEqual(a.Results, b.Results) {
+		t.Errorf("pages of a cached search: %+v and %+v", a, b)
+	}
+
+	w := httptest.NewRecorder()
+	SearchCacheHandler(w, httptest.NewRequest(http.MethodGet, "/search/cache", nil))
+	var stats CacheStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil || stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("GET /search/cache = %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"tracker/src"
+)
+
+// ConcertReportHandler serves /admin/concerts: the number of concerts of the
+// catalog and the malformed entries found while loading them, as JSON.
+func ConcertReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wrongMethodHandler(w)
+		return
+	}
+	if err := Catalog.EnsureLoaded(); err != nil {
+		InternalServerHandler(w)
+		log.Println(err)
+		return
+	}
+
+	snap := Catalog.Snapshot()
+	report := struct {
+		Version uint64 `json:"version"` // Of the snapshot the report is about
+		src.ConcertReport
+	}{snap.Version, snap.ConcertReport()}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
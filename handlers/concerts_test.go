@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tracker/src"
+)
+
+func TestConcertReportHandler(t *testing.T) {
+	useFixtureCatalog(t)
+
+	w := httptest.NewRecorder()
+	ConcertReportHandler(w, httptest.NewRequest(http.MethodGet, "/admin/concerts", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /admin/concerts = %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var report struct {
+		Version uint64 `json:"version"`
+		src.ConcertReport
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding report: %v", err)
+	}
+	if report.Version == 0 || report.Concerts == 0 || report.Valid != report.Concerts || report.Issues == nil || len(report.Issues) != 0 {
+		t.Errorf("report = %+v, want every fixture concert valid", report)
+	}
+
+	w = httptest.NewRecorder()
+	ConcertReportHandler(w, httptest.NewRequest(http.MethodPost, "/admin/concerts", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /admin/concerts = %d", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+
+	model "tracker/models"
+	"tracker/src"
+)
+
+// Facet is one bucket of a facet: a value and how many matched it
+type Facet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFacets break down every match of a search, before paging. Type
+// counts results, including those of the types the search leaves out so
+// that another can be picked; the other facets count the distinct artists
+// behind the results kept.
+type SearchFacets struct {
+	Type    []Facet `json:"type"`    // In the order of resultTypes
+	Country []Facet `json:"country"` // Countries played in, most artists first
+	Decade  []Facet `json:"decade"`  // Decades of creation, e.g. "1970s", oldest first
+	Members []Facet `json:"members"` // Number of members, fewest first
+}
+
+// locationCountry returns the country of a location key, such as "USA"
+// for "north_carolina-usa"
+func locationCountry(location string) string {
+	return model.ParseLocation(location).Country
+}
+
+// facetResults computes the facets of results found in snap
+func facetResults(snap *src.Snapshot, results []SearchResult) *SearchFacets {
+	countries := make(map[string]int)
+	decades := make(map[int]int)
+	members := make(map[int]int)
+
+	seen := make(map[int]bool)
+	for _, result := range results {
+		if seen[result.ID] {
+			continue
+		}
+		seen[result.ID] = true
+
+		artist, ok := snap.Artist(result.ID)
+		if !ok {
+			continue
+		}
+		decades[artist.CreationDate/10*10]++
+		members[len(artist.Members)]++
+
+		played := make(map[string]bool)
+		for location := range artist.DateAndLocation {
+			played[locationCountry(location)] = true
+		}
+		if locations, ok := snap.Location(result.ID); ok {
+			for _, location := range locations.Locations {
+				played[locationCountry(location)] = true
+			}
+		}
+		for country := range played {
+			countries[country]++
+		}
+	}
+
+	facets := &SearchFacets{
+		Type:    typeFacet(results),
+		Country: []Facet{},
+		Decade:  []Facet{},
+		Members: []Facet{},
+	}
+
+	for country, n := range countries {
+		facets.Country = append(facets.Country, Facet{country, n})
+	}
+	sort.Slice(facets.Country, func(i, j int) bool {
+		a, b := facets.Country[i], facets.Country[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Value < b.Value
+	})
+
+	for _, decade := range sortedInts(decades) {
+		facets.Decade = append(facets.Decade, Facet{strconv.Itoa(decade) + "s", decades[decade]})
+	}
+	for _, n := range sortedInts(members) {
+		facets.Members = append(facets.Members, Facet{strconv.Itoa(n), members[n]})
+	}
+	return facets
+}
+
+// typeFacet counts results per type, in the order of resultTypes
+func typeFacet(results []SearchResult) []Facet {
+	types := make(map[string]int)
+	for _, result := range results {
+		types[result.Type]++
+	}
+	facet := []Facet{}
+	for _, t := range resultTypes {
+		if types[t] > 0 {
+			facet = append(facet, Facet{t, types[t]})
+		}
+	}
+	return facet
+}
+
+// sortedInts returns the keys of m in ascending order
+func sortedInts(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func facetString(facets []Facet) string {
+	out := ""
+	for _, f := range facets {
+		out += fmt.Sprintf("%s=%d ", f.Value, f.Count)
+	}
+	return out
+}
+
+func TestSearchHandlerFacets(t *testing.T) {
+	useFixtureCatalog(t)
+
+	// Scorpions, Red Hot Chili Peppers and Gorillaz played in Germany
+	resp := searchResponse(t, "q=germany&limit=1")
+	if resp.Facets == nil {
+		t.Fatalf("no facets")
+	}
+	want := map[string]string{
+		"type":    "location=4 ",
+		"country": "Germany=3 Brazil=2 Mexico=2 UK=2 USA=2 New Zealand=1 ",
+		"decade":  "1960s=1 1980s=1 1990s=1 ",
+		"members": "2=1 4=1 5=1 ",
+	}
+	got := map[string]string{
+		"type":    facetString(resp.Facets.Type),
+		"country": facetString(resp.Facets.Country),
+		"decade":  facetString(resp.Facets.Decade),
+		"members": facetString(resp.Facets.Members),
+	}
+	for name := range want {
+		if got[name] != want[name] {
+			t.Errorf("%s facet = %q, want %q", name, got[name], want[name])
+		}
+	}
+
+	// facets cover every match, whatever the page
+	if all := searchResponse(t, "q=germany&limit=100&offset=2"); !reflect.DeepEqual(all.Facets, resp.Facets) {
+		t.Errorf("facets changed with the page: %+v, want %+v", all.Facets, resp.Facets)
+	}
+
+	// the type facet still counts the types left out, so another can be
+	// picked, while the artist facets only cover the matches kept
+	all := searchResponse(t, "q=e&limit=100")
+	filtered := searchResponse(t, "q=e&type=artist&limit=100")
+	if f, want := facetString(filtered.Facets.Type), facetString(all.Facets.Type); f != want {
+		t.Errorf("type facet with a type filter = %q, want %q", f, want)
+	}
+	if !strings.Contains(facetString(filtered.Facets.Type), fmt.Sprintf("artist=%d ", filtered.Total)) {
+		t.Errorf("type facet %q does not count the %d artists kept", facetString(filtered.Facets.Type), filtered.Total)
+	}
+	members := 0
+	for _, f := range filtered.Facets.Members {
+		members += f.Count
+	}
+	if members != filtered.Total {
+		t.Errorf("members facet counts %d artists, want %d", members, filtered.Total)
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	model "tracker/models"
+	"tracker/search"
+	"tracker/src"
+)
+
+// yearRange is an inclusive range of years. A zero bound is open.
+type yearRange struct {
+	From, To int
+}
+
+func (r yearRange) contains(year int) bool {
+	return (r.From == 0 || year >= r.From) && (r.To == 0 || year <= r.To)
+}
+
+// String formats r as it is written in the URL, or "" when r is open
+func (r yearRange) String() string {
+	switch {
+	case r.From == 0 && r.To == 0:
+		return ""
+	case r.From == r.To:
+		return strconv.Itoa(r.From)
+	case r.To == 0:
+		return strconv.Itoa(r.From) + "-"
+	case r.From == 0:
+		return "-" + strconv.Itoa(r.To)
+	}
+	return strconv.Itoa(r.From) + "-" + strconv.Itoa(r.To)
+}
+
+// parseFilterYears parses the year ranges of homepage filters: "1970-1990",
+// "1970-", "-1990" or "1980"
+func parseFilterYears(s string) (yearRange, error) {
+	from, to, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	year := func(s string) (int, error) {
+		s = strings.TrimSpace(s)
+		if s == "" && isRange {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1000 || n > 9999 {
+			return 0, errors.New("bad year")
+		}
+		return n, nil
+	}
+
+	var r yearRange
+	var err1, err2 error
+	r.From, err1 = year(from)
+	r.To, err2 = year(to)
+	if !isRange {
+		r.To, err2 = r.From, nil
+	}
+	if err1 != nil || err2 != nil || r == (yearRange{}) || r.From != 0 && r.To != 0 && r.From > r.To {
+		return yearRange{}, fmt.Errorf("invalid year range %q", s)
+	}
+	return r, nil
+}
+
+// listValues returns the comma separated items of every value of key, so
+// that "members=4,5" and "members=4&members=5" mean the same
+func listValues(values url.Values, key string) []string {
+	var out []string
+	for _, value := range values[key] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// artistFilter narrows the artists of the homepage. Each part left empty
+// lets every artist through; the parts that are set must all match.
+type artistFilter struct {
+	Query     string       // The search box: artists with a search result
+	Created   yearRange    // Year of creation
+	Album     yearRange    // Year of the first album
+	Members   map[int]bool // Allowed numbers of members
+	Locations []string     // Cities, regions, countries or country codes, any of which the artist played, folded
+}
+
+// parseArtistFilter reads the filter of a homepage URL:
+//
+//	/?q=queen&created=1970-1990&album=1980-1995&members=4,5&location=germany
+func parseArtistFilter(values url.Values) (artistFilter, error) {
+	filter := artistFilter{Query: strings.TrimSpace(values.Get("q"))}
+
+	var err error
+	if value := values.Get("created"); value != "" {
+		if filter.Created, err = parseFilterYears(value); err != nil {
+			return filter, fmt.Errorf("created: %w", err)
+		}
+	}
+	if value := values.Get("album"); value != "" {
+		if filter.Album, err = parseFilterYears(value); err != nil {
+			return filter, fmt.Errorf("album: %w", err)
+		}
+	}
+
+	for _, item := range listValues(values, "members") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 1 {
+			return filter, fmt.Errorf("members: invalid number %q", item)
+		}
+		if filter.Members == nil {
+			filter.Members = make(map[int]bool)
+		}
+		filter.Members[n] = true
+	}
+
+	for _, item := range listValues(values, "location") {
+		if folded := search.Fold(item); folded != "" {
+			filter.Locations = append(filter.Locations, folded)
+		}
+	}
+	return filter, nil
+}
+
+// Active reports whether any filter besides the search box is set
+func (f artistFilter) Active() bool {
+	return f.Created != yearRange{} || f.Album != yearRange{} || f.Members != nil || len(f.Locations) > 0
+}
+
+// HasLocation reports whether the filter asks for location, for the
+// checkboxes of the homepage
+func (f artistFilter) HasLocation(location string) bool {
+	folded := search.Fold(location)
+	for _, l := range f.Locations {
+		if l == folded {
+			return true
+		}
+	}
+	return false
+}
+
+// albumYear returns the year of the first album of artist, or zero
+func albumYear(artist model.Data) int {
+	t, err := search.ParseDate(artist.FirstAlbum)
+	if err != nil {
+		return 0
+	}
+	return t.Year()
+}
+
+// artistPlaces returns every location key artist played, from both the
+// relations and the locations endpoints
+func artistPlaces(snap *src.Snapshot, artist model.Data) []string {
+	var places []string
+	for place := range artist.DateAndLocation {
+		places = append(places, place)
+	}
+	if locations, ok := snap.Location(artist.Id); ok {
+		places = append(places, locations.Locations...)
+	}
+	return places
+}
+
+// matches reports whether artist, who played places, passes the filter.
+// The search box is checked by filterArtists.
+func (f artistFilter) matches(artist model.Data, places []string) bool {
+	if !f.Created.contains(artist.CreationDate) {
+		return false
+	}
+	if f.Album != (yearRange{}) && !f.Album.contains(albumYear(artist)) {
+		return false
+	}
+	if f.Members != nil && !f.Members[len(artist.Members)] {
+		return false
+	}
+	if len(f.Locations) == 0 {
+		return true
+	}
+	for _, key := range places {
+		place := model.ParseLocation(key)
+		names := []string{search.Fold(key), search.Fold(place.City), search.Fold(place.Region),
+			search.Fold(place.Country), search.Fold(place.Code)}
+		for _, location := range f.Locations {
+			for _, name := range names {
+				if name == location {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// filterArtists returns, in catalog order, the artists of snap passing
+// filter. With a query, only the artists behind one of its search results
+// pass.
+func filterArtists(ctx context.Context, snap *src.Snapshot, filter artistFilter) ([]model.Data, error) {
+	var found map[int]bool
+	if filter.Query != "" {
+		resp, _, err := cachedSearch(ctx, snap, filter.Query, searchParams{limit: defaultSearchLimit})
+		if err != nil {
+			return nil, err
+		}
+		SearchAnalytics.RecordSearch(filter.Query, resp.Total)
+		found = make(map[int]bool, len(resp.Results))
+		for _, result := range resp.Results {
+			found[result.ID] = true
+		}
+	}
+
+	artists := []model.Data{}
+	for _, artist := range snap.Artists() {
+		if found != nil && !found[artist.Id] {
+			continue
+		}
+		if filter.matches(artist, artistPlaces(snap, artist)) {
+			artists = append(artists, artist)
+		}
+	}
+	return artists, nil
+}
+
+// filterBounds are what the filter controls of the homepage offer: the
+// range of each year slider and the choices of each list
+type filterBounds struct {
+	Created   yearRange
+	Album     yearRange
+	Members   []int
+	Countries []string
+}
+
+// homepageBounds computes the filter controls for artists of snap
+func homepageBounds(snap *src.Snapshot, artists []model.Data) filterBounds {
+	var bounds filterBounds
+	members := make(map[int]int)
+	countries := make(map[string]bool)
+	widen := func(r *yearRange, year int) {
+		if year == 0 {
+			return
+		}
+		if r.From == 0 || year < r.From {
+			r.From = year
+		}
+		if year > r.To {
+			r.To = year
+		}
+	}
+
+	for _, artist := range artists {
+		widen(&bounds.Created, artist.CreationDate)
+		widen(&bounds.Album, albumYear(artist))
+		members[len(artist.Members)]++
+		for _, place := range artistPlaces(snap, artist) {
+			countries[locationCountry(place)] = true
+		}
+	}
+
+	bounds.Members = sortedInts(members)
+	for country := range countries {
+		bounds.Countries = append(bounds.Countries, country)
+	}
+	sort.Strings(bounds.Countries)
+	return bounds
+}
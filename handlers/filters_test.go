@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_parseFilterYears(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    yearRange
+		wantErr bool
+	}{
+		{"1970-1990", yearRange{1970, 1990}, false},
+		{"1970-", yearRange{1970, 0}, false},
+		{"-1990", yearRange{0, 1990}, false},
+		{"1980", yearRange{1980, 1980}, false},
+		{" 1970 - 1990 ", yearRange{1970, 1990}, false},
+		{"1990-1970", yearRange{}, true},
+		{"-", yearRange{}, true},
+		{"70-90", yearRange{}, true},
+		{"seventies", yearRange{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseFilterYears(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseFilterYears(%q) = %v, %v, want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+		if err == nil && tt.value == strings.ReplaceAll(tt.value, " ", "") && got.String() != tt.value {
+			t.Errorf("%v.String() = %q, want %q", got, got.String(), tt.value)
+		}
+	}
+}
+
+func Test_parseArtistFilter(t *testing.T) {
+	values, _ := url.ParseQuery("created=1970-1990&members=4,5&members=7&location=New_Zealand&location=usa")
+	filter, err := parseArtistFilter(values)
+	if err != nil {
+		t.Fatalf("parseArtistFilter() error = %v", err)
+	}
+	if !filter.Members[4] || !filter.Members[5] || !filter.Members[7] || len(filter.Members) != 3 {
+		t.Errorf("Members = %v", filter.Members)
+	}
+	if strings.Join(filter.Locations, ",") != "new zealand,usa" || !filter.HasLocation("new_zealand") {
+		t.Errorf("Locations = %q", filter.Locations)
+	}
+	if !filter.Active() {
+		t.Errorf("filter is not active")
+	}
+
+	for _, bad := range []string{"created=soon", "album=2000-1990", "members=four", "members=0"} {
+		values, _ := url.ParseQuery(bad)
+		if _, err := parseArtistFilter(values); err == nil {
+			t.Errorf("parseArtistFilter(%s) accepted", bad)
+		}
+	}
+}
+
+func TestHomepageHandlerFilters(t *testing.T) {
+	t.Setenv("TEST_MODE", "true")
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		rawQuery string
+		want     []string
+	}{
+		{"", []string{"Queen", "SOJA", "Pink Floyd", "Scorpions", "Red Hot Chili Peppers", "Gorillaz"}},
+		{"created=1970-1990", []string{"Queen", "Red Hot Chili Peppers"}},
+		{"album=1980-1995", []string{"Red Hot Chili Peppers"}},
+		{"members=4,5", []string{"Pink Floyd", "Scorpions", "Red Hot Chili Peppers"}},
+		{"members=5&location=germany", []string{"Scorpions"}},
+		{"location=new_zealand", []string{"Queen", "Red Hot Chili Peppers"}},
+		{"created=1960-1970&location=uk", []string{"Pink Floyd"}},
+		{"q=queen&created=-1980", []string{"Queen"}},
+		{"created=2000-", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rawQuery, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HomepageHandler(w, httptest.NewRequest(http.MethodGet, "/?"+tt.rawQuery, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET /?%s = %d", tt.rawQuery, w.Code)
+			}
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")[1:]
+			if strings.Join(lines, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("artists = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	HomepageHandler(w, httptest.NewRequest(http.MethodGet, "/?members=many", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad filter = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
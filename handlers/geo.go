@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	model "tracker/models"
+)
+
+// GeoLocation is a location of the catalog with its coordinates and who
+// played there.
+type GeoLocation struct {
+	model.Place
+	Artists  []int `json:"artists"`  // Ids of the artists who played there, ascending
+	Concerts int   `json:"concerts"` // Dated concerts there
+}
+
+// GeoResponse is the body of /api/locations/geo.
+type GeoResponse struct {
+	Locations  []GeoLocation `json:"locations"`  // Located places, by key
+	Unresolved []string      `json:"unresolved"` // Keys the gazetteer does not know, sorted
+}
+
+// LocationsGeoHandler serves /api/locations/geo: every location of the
+// catalog, or of the artist given by id, with its coordinates.
+func LocationsGeoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wrongMethodHandler(w)
+		return
+	}
+
+	artistID := 0
+	if id := r.URL.Query().Get("id"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil || n <= 0 {
+			badRequestHandler(w)
+			return
+		}
+		artistID = n
+	}
+
+	if err := Catalog.EnsureLoaded(); err != nil {
+		InternalServerHandler(w)
+		log.Println(err)
+		return
+	}
+	snap := Catalog.Snapshot()
+	if _, ok := snap.Artist(artistID); artistID != 0 && !ok {
+		notFoundHandler(w)
+		return
+	}
+
+	artists := make(map[string][]int)
+	concerts := make(map[string]int)
+	for _, artist := range snap.Artists() {
+		if artistID != 0 && artist.Id != artistID {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, key := range artistPlaces(snap, artist) {
+			if !seen[key] {
+				seen[key] = true
+				artists[key] = append(artists[key], artist.Id)
+			}
+		}
+		for _, concert := range snap.ArtistConcerts(artist.Id) {
+			if concert.Valid() && !concert.Flags.Has(model.Duplicate) {
+				concerts[concert.Location.Key]++
+			}
+		}
+	}
+
+	resp := GeoResponse{Locations: []GeoLocation{}, Unresolved: []string{}}
+	for _, place := range snap.Places() {
+		ids, ok := artists[place.Key]
+		if !ok {
+			continue
+		}
+		if place.Coords == nil {
+			resp.Unresolved = append(resp.Unresolved, place.Key)
+			continue
+		}
+		sort.Ints(ids)
+		resp.Locations = append(resp.Locations, GeoLocation{Place: place, Artists: ids, Concerts: concerts[place.Key]})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
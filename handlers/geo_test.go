@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	model "tracker/models"
+)
+
+func geoResponse(t *testing.T, rawQuery string) (int, GeoResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	LocationsGeoHandler(w, httptest.NewRequest(http.MethodGet, "/api/locations/geo?"+rawQuery, nil))
+	var resp GeoResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding %s: %v", rawQuery, err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestLocationsGeoHandler(t *testing.T) {
+	useFixtureCatalog(t)
+	gazetteer, err := model.LoadGazetteer("../data/gazetteer.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Catalog.SetGazetteer(gazetteer)
+	if err := Catalog.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp := geoResponse(t, "")
+	if code != http.StatusOK || len(resp.Locations) != 24 || len(resp.Unresolved) != 0 {
+		t.Fatalf("GET /api/locations/geo = %d with %d locations, unresolved %v", code, len(resp.Locations), resp.Unresolved)
+	}
+	for _, l := range resp.Locations {
+		if l.Coords == nil || len(l.Artists) == 0 || l.Concerts == 0 {
+			t.Errorf("location %+v lacks coordinates, artists or concerts", l)
+		}
+	}
+
+	// only the places of Queen (1), such as Osaka
+	code, resp = geoResponse(t, "id=1")
+	if code != http.StatusOK {
+		t.Fatalf("GET /api/locations/geo?id=1 = %d", code)
+	}
+	found := false
+	for _, l := range resp.Locations {
+		if len(l.Artists) != 1 || l.Artists[0] != 1 {
+			t.Errorf("id=1 returned %s for artists %v", l.Key, l.Artists)
+		}
+		if l.Key == "osaka-japan" {
+			found = true
+			if l.Name != "Osaka, Japan" || l.Code != "JP" || l.Coords.Lat < 34 || l.Coords.Lat > 35 {
+				t.Errorf("osaka-japan = %+v", l)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("id=1 locations = %+v, want osaka-japan", resp.Locations)
+	}
+
+	for query, want := range map[string]int{"id=x": http.StatusBadRequest, "id=0": http.StatusBadRequest, "id=99": http.StatusNotFound} {
+		if code, _ := geoResponse(t, query); code != want {
+			t.Errorf("GET /api/locations/geo?%s = %d, want %d", query, code, want)
+		}
+	}
+}
+
+func TestLocationsGeoHandlerWithoutGazetteer(t *testing.T) {
+	useFixtureCatalog(t)
+
+	code, resp := geoResponse(t, "id=3")
+	if code != http.StatusOK || len(resp.Locations) != 0 || len(resp.Unresolved) != 4 {
+		t.Errorf("GET /api/locations/geo?id=3 without a gazetteer = %d, %+v, want every place unresolved", code, resp)
+	}
+}
@@ -0,0 +1,583 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"tracker/search"
+	"tracker/src"
+)
+
+// The /search query language. Plain text keeps its old meaning: the whole
+// query is matched against every category. On top of that a query may use
+//
+//	member:freddie             a single category: artist (or name), member,
+//	location:"new york"        location, year (or creation), album and date
+//	                           (or concert)
+//	date:2019-05..2019-06      concerts within a date window, see
+//	                           search.ParseDateWindow
+//	year:1970..1980            inclusive ranges, open ended with 1970.. or ..1980
+//	year:1970s  album:<1990    decades and comparisons (<, <=, >, >=)
+//	"pink floyd"               a quoted phrase
+//	a AND b, a OR b, NOT a     boolean operators, upper case, with ( )
+//
+// Terms next to each other are combined with AND. Operators apply to artists:
+// `year:1970s location:germany` returns the matches of both terms, limited to
+// artists formed in the 70s that played in Germany.
+
+// querySyntaxError reports a query the parser could not make sense of.
+type querySyntaxError struct {
+	msg string
+}
+
+func (e *querySyntaxError) Error() string {
+	return "invalid query: " + e.msg
+}
+
+// queryNode is a parsed query: a *queryTerm, *queryAnd, *queryOr or *queryNot.
+type queryNode interface {
+	String() string
+}
+
+type queryTerm struct {
+	field  string // "" for any category
+	value  string
+	phrase bool // value was quoted
+}
+
+type queryAnd struct {
+	left, right queryNode
+	explicit    bool // written as AND rather than implied by a space
+}
+
+type queryOr struct {
+	left, right queryNode
+}
+
+type queryNot struct {
+	child queryNode
+}
+
+func (t *queryTerm) String() string {
+	value := t.value
+	if t.phrase {
+		value = strconv.Quote(value)
+	}
+	if t.field == "" {
+		return value
+	}
+	return t.field + ":" + value
+}
+
+func (n *queryAnd) String() string { return "(" + n.left.String() + " AND " + n.right.String() + ")" }
+
+func (n *queryOr) String() string { return "(" + n.left.String() + " OR " + n.right.String() + ")" }
+
+func (n *queryNot) String() string { return "NOT " + n.child.String() }
+
+// queryFields maps the field names accepted before a colon onto categories
+var queryFields = map[string]string{
+	"artist":     "artist",
+	"name":       "artist",
+	"member":     "member",
+	"location":   "location",
+	"year":       "year",
+	"creation":   "year",
+	"album":      "album",
+	"firstalbum": "album",
+	"date":       "date",
+	"concert":    "date",
+}
+
+// queryToken is a word, phrase, parenthesis or operator of a query
+type queryToken struct {
+	kind  string // "word", "phrase", "(", ")", "AND", "OR", "NOT"
+	field string // set on words and phrases written field:value
+	text  string
+}
+
+// lexQuery splits raw into tokens
+func lexQuery(raw string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(raw)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{kind: string(r)})
+			i++
+		case r == '"':
+			end := indexRune(runes, '"', i+1)
+			if end < 0 {
+				return nil, &querySyntaxError{"unterminated quote"}
+			}
+			tokens = append(tokens, queryToken{kind: "phrase", text: string(runes[i+1 : end])})
+			i = end + 1
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+				i++
+			}
+			word := string(runes[start:i])
+
+			if word == "AND" || word == "OR" || word == "NOT" {
+				tokens = append(tokens, queryToken{kind: word})
+				continue
+			}
+
+			name, value, found := strings.Cut(word, ":")
+			field, known := queryFields[strings.ToLower(name)]
+			if !found || !known {
+				tokens = append(tokens, queryToken{kind: "word", text: word})
+				continue
+			}
+			if value == "" && i < len(runes) && runes[i] == '"' {
+				end := indexRune(runes, '"', i+1)
+				if end < 0 {
+					return nil, &querySyntaxError{"unterminated quote"}
+				}
+				tokens = append(tokens, queryToken{kind: "phrase", field: field, text: string(runes[i+1 : end])})
+				i = end + 1
+				continue
+			}
+			if value == "" {
+				return nil, &querySyntaxError{fmt.Sprintf("%s: needs a value", name)}
+			}
+			tokens = append(tokens, queryToken{kind: "word", field: field, text: value})
+		}
+	}
+	return tokens, nil
+}
+
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// queryParser builds a query tree from tokens, following
+//
+//	query   = or
+//	or      = and { "OR" and }
+//	and     = unary { ["AND"] unary }
+//	unary   = "NOT" unary | "(" query ")" | term
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// parseQuery parses raw into a query tree, or returns nil for a blank query.
+// Text without operators or fields that still does not parse, such as a
+// name typed up to an opening quote or parenthesis, is read as plain text:
+// only malformed uses of the query language are syntax errors.
+func parseQuery(raw string) (queryNode, error) {
+	node, err := parseTokens(raw)
+	if err != nil && !usesOperators(raw) {
+		return plainQuery(raw), nil
+	}
+	return node, err
+}
+
+func parseTokens(raw string) (queryNode, error) {
+	tokens, err := lexQuery(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &queryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, &querySyntaxError{fmt.Sprintf("unexpected %q", p.tokens[p.pos].kind)}
+	}
+	return node, nil
+}
+
+// queryWords splits raw into words at spaces, quotes and parentheses
+func queryWords(raw string) []string {
+	return strings.FieldsFunc(raw, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '(' || r == ')'
+	})
+}
+
+// usesOperators reports whether raw holds a boolean operator or a
+// field:value term
+func usesOperators(raw string) bool {
+	for _, word := range queryWords(raw) {
+		if word == "AND" || word == "OR" || word == "NOT" {
+			return true
+		}
+		if name, _, found := strings.Cut(word, ":"); found && queryFields[strings.ToLower(name)] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// plainQuery returns the words of raw as plain text, or nil if it has none
+func plainQuery(raw string) queryNode {
+	var node queryNode
+	for _, word := range queryWords(raw) {
+		term := &queryTerm{value: word}
+		if node == nil {
+			node = term
+		} else {
+			node = &queryAnd{left: node, right: term}
+		}
+	}
+	return node
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].kind
+	}
+	return ""
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryOr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		explicit := false
+		switch p.peek() {
+		case "AND":
+			explicit = true
+			p.pos++
+		case "", "OR", ")":
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryAnd{left: left, right: right, explicit: explicit}
+	}
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	switch p.peek() {
+	case "":
+		return nil, &querySyntaxError{"unexpected end of query"}
+	case "NOT":
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{child: child}, nil
+	case "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, &querySyntaxError{"missing )"}
+		}
+		p.pos++
+		return node, nil
+	case "word", "phrase":
+		token := p.tokens[p.pos]
+		p.pos++
+		return &queryTerm{field: token.field, value: token.text, phrase: token.kind == "phrase"}, nil
+	default:
+		return nil, &querySyntaxError{fmt.Sprintf("unexpected %q", p.peek())}
+	}
+}
+
+// isPlainText reports whether node is nothing but words separated by spaces,
+// which keep their free-text meaning.
+func isPlainText(node queryNode) bool {
+	switch n := node.(type) {
+	case *queryTerm:
+		return n.field == "" && !n.phrase
+	case *queryAnd:
+		return !n.explicit && isPlainText(n.left) && isPlainText(n.right)
+	}
+	return false
+}
+
+// filterScore is given to artists matched only through NOT, which has no
+// text to rank by
+const filterScore = 50
+
+// rangeScore is given to year and album range matches: an exact match in a
+// date field
+const rangeScore = 80
+
+// runQuery answers a raw /search query
+func runQuery(ctx context.Context, scope searchScope, raw string) ([]SearchResult, error) {
+	node, err := parseQuery(raw)
+	if err != nil || node == nil {
+		return nil, err
+	}
+	if isPlainText(node) {
+		return searchAll(ctx, scope, strings.ToLower(strings.TrimSpace(raw)))
+	}
+
+	ids, results := evalQuery(ctx, scope, node)
+
+	var selected []SearchResult
+	for _, result := range results {
+		if ids[result.ID] {
+			selected = append(selected, result)
+		}
+	}
+	allResults := appendUnique(nil, selected...)
+
+	// e.g. "NOT location:usa": the matching artists themselves
+	if len(allResults) == 0 {
+		for _, artist := range scope.snap.Artists() {
+			if ids[artist.Id] {
+				allResults = append(allResults, SearchResult{
+					Type:  "artist",
+					ID:    artist.Id,
+					Text:  artist.Name,
+					Score: filterScore,
+				})
+			}
+		}
+	}
+	return allResults, nil
+}
+
+// evalQuery returns the artists node selects and the results its terms
+// matched. Results of terms under NOT are dropped.
+func evalQuery(ctx context.Context, scope searchScope, node queryNode) (map[int]bool, []SearchResult) {
+	switch n := node.(type) {
+	case *queryTerm:
+		results := termResults(ctx, scope, n)
+		ids := make(map[int]bool)
+		for _, result := range results {
+			ids[result.ID] = true
+		}
+		return ids, results
+
+	case *queryAnd:
+		leftIDs, leftResults := evalQuery(ctx, scope, n.left)
+		rightIDs, rightResults := evalQuery(ctx, scope, n.right)
+		ids := make(map[int]bool)
+		for id := range leftIDs {
+			if rightIDs[id] {
+				ids[id] = true
+			}
+		}
+		return ids, append(leftResults, rightResults...)
+
+	case *queryOr:
+		leftIDs, leftResults := evalQuery(ctx, scope, n.left)
+		rightIDs, rightResults := evalQuery(ctx, scope, n.right)
+		for id := range rightIDs {
+			leftIDs[id] = true
+		}
+		return leftIDs, append(leftResults, rightResults...)
+
+	case *queryNot:
+		childIDs, _ := evalQuery(ctx, scope, n.child)
+		ids := make(map[int]bool)
+		for _, artist := range scope.snap.Artists() {
+			if !childIDs[artist.Id] {
+				ids[artist.Id] = true
+			}
+		}
+		return ids, nil
+	}
+	return nil, nil
+}
+
+// termCategory is a category of the query language searched in the index:
+// its name before a colon, the type of its results and its field
+type termCategory struct {
+	name, resultType string
+	field            search.Field
+}
+
+// termCategories are the categories searched in the index, in the order of
+// searchFuncs
+var termCategories = []termCategory{
+	{"artist", "artist", search.FieldName},
+	{"location", "location", search.FieldLocation},
+	{"year", "creation", search.FieldCreation},
+	{"album", "First Album", search.FieldFirstAlbum},
+	{"member", "member", search.FieldMember},
+}
+
+// termResults returns every match of a single term. Quoted terms only match
+// values holding them as one piece, with no typos allowed for.
+func termResults(ctx context.Context, scope searchScope, t *queryTerm) []SearchResult {
+	query := strings.ToLower(t.value)
+
+	switch t.field {
+	case "":
+		if !t.phrase {
+			results, _ := searchAll(ctx, scope, query)
+			return results
+		}
+		var results []SearchResult
+		for _, c := range termCategories {
+			results = appendUnique(results, phraseResults(ctx, scope, query, c)...)
+		}
+		concerts, _ := searchConcerts(ctx, scope, query)
+		return appendUnique(results, concerts...)
+	case "date":
+		results, _ := searchConcerts(ctx, scope, query)
+		return results
+	case "year", "album":
+		if lo, hi, ok := parseYearRange(query); ok {
+			resultType := "creation"
+			if t.field == "album" {
+				resultType = "First Album"
+			}
+			return yearResults(scope.snap, resultType, lo, hi)
+		}
+	}
+
+	for _, c := range termCategories {
+		switch {
+		case c.name != t.field:
+		case t.phrase:
+			return phraseResults(ctx, scope, query, c)
+		default:
+			return indexResults(ctx, scope, query, c.resultType, c.field)
+		}
+	}
+	return nil
+}
+
+// phraseResults returns the results of category whose value holds phrase
+func phraseResults(ctx context.Context, scope searchScope, phrase string, category termCategory) []SearchResult {
+	if ctx.Err() != nil {
+		return nil
+	}
+	hits := scope.snap.Index().SearchPhrase(phrase, category.field)
+	return hitResults(scope.snap, hits, phrase, category.resultType, category.field)
+}
+
+// parseYearRange parses the year filters of the query language into an
+// inclusive range: 1975, 1970s, 1970..1980, 1970.., ..1980, <1990, <=1990,
+// >1990 and >=1990. Anything else is not a range and is searched as text.
+func parseYearRange(value string) (lo, hi int, ok bool) {
+	year := func(s string) (int, bool) {
+		if len(s) != 4 {
+			return 0, false
+		}
+		n, err := strconv.Atoi(s)
+		return n, err == nil
+	}
+
+	if from, to, found := strings.Cut(value, ".."); found {
+		lo, hi = math.MinInt, math.MaxInt
+		if from != "" {
+			if lo, ok = year(from); !ok {
+				return 0, 0, false
+			}
+		}
+		if to != "" {
+			if hi, ok = year(to); !ok {
+				return 0, 0, false
+			}
+		}
+		return lo, hi, (from != "" || to != "") && lo <= hi
+	}
+
+	for _, op := range []string{"<=", ">=", "<", ">"} {
+		rest, found := strings.CutPrefix(value, op)
+		if !found {
+			continue
+		}
+		n, ok := year(rest)
+		if !ok {
+			return 0, 0, false
+		}
+		switch op {
+		case "<=":
+			return math.MinInt, n, true
+		case ">=":
+			return n, math.MaxInt, true
+		case "<":
+			return math.MinInt, n - 1, true
+		default:
+			return n + 1, math.MaxInt, true
+		}
+	}
+
+	if decade, found := strings.CutSuffix(value, "s"); found {
+		if n, ok := year(decade); ok && n%10 == 0 {
+			return n, n + 9, true
+		}
+		return 0, 0, false
+	}
+
+	if n, ok := year(value); ok {
+		return n, n, true
+	}
+	return 0, 0, false
+}
+
+// yearResults returns the artists of snap whose creation year ("creation")
+// or first album year ("First Album") lies within lo..hi
+func yearResults(snap *src.Snapshot, resultType string, lo, hi int) []SearchResult {
+	var results []SearchResult
+
+	for _, artist := range snap.Artists() {
+		year, context := artist.CreationDate, strconv.Itoa(artist.CreationDate)
+		if resultType == "First Album" {
+			context = artist.FirstAlbum
+			parsed, err := strconv.Atoi(context[strings.LastIndex(context, "-")+1:])
+			if err != nil {
+				continue
+			}
+			year = parsed
+		}
+
+		if year >= lo && year <= hi {
+			// the year is the last four characters of both kinds of context
+			n := utf8.RuneCountInString(context)
+			results = append(results, SearchResult{
+				Type:       resultType,
+				ID:         artist.Id,
+				Text:       artist.Name,
+				Context:    context,
+				Score:      rangeScore,
+				Highlights: []Highlight{{Field: "context", Start: n - 4, End: n}},
+			})
+		}
+	}
+
+	return results
+}
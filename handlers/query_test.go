@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_parseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"freddie mercury", "(freddie AND mercury)", false},
+		{`member:freddie location:"new york"`, `(member:freddie AND location:"new york")`, false},
+		{"year:1970..1980 OR album:<1990", "(year:1970..1980 OR album:<1990)", false},
+		{"a OR b c", "(a OR (b AND c))", false},
+		{"NOT member:flea AND (x OR y)", "(NOT member:flea AND (x OR y))", false},
+		{`"pink floyd"`, `"pink floyd"`, false},
+		{"Name:Queen", "artist:Queen", false},
+		{"ac:dc", "ac:dc", false},
+		{"and or not", "((and AND or) AND not)", false},
+		{"", "", false},
+		{`"unterminated`, "unterminated", false}, // plain text typed halfway
+		{"(queen", "queen", false},
+		{"queen)", "queen", false},
+		{`guns n' roses "`, "((guns AND n') AND roses)", false},
+		{`member:"freddie`, "", true},
+		{"(queen AND pink", "", true},
+		{"queen OR", "", true},
+		{"NOT", "", true},
+		{"member:", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := parseQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := ""
+			if node != nil {
+				got = node.String()
+			}
+			if got != tt.want {
+				t.Errorf("parseQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isPlainText(t *testing.T) {
+	tests := map[string]bool{
+		"freddie mercury":   true,
+		"ac:dc":             true,
+		`"freddie mercury"`: false,
+		"freddie AND queen": false,
+		"member:freddie":    false,
+		"NOT queen":         false,
+		"(freddie)":         true,
+	}
+	for query, want := range tests {
+		node, err := parseQuery(query)
+		if err != nil {
+			t.Fatalf("parseQuery(%q) error = %v", query, err)
+		}
+		if got := isPlainText(node); got != want {
+			t.Errorf("isPlainText(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func Test_parseYearRange(t *testing.T) {
+	tests := []struct {
+		value  string
+		lo, hi int
+		ok     bool
+	}{
+		{"1975", 1975, 1975, true},
+		{"1970s", 1970, 1979, true},
+		{"1970..1980", 1970, 1980, true},
+		{"1970..", 1970, math.MaxInt, true},
+		{"..1980", math.MinInt, 1980, true},
+		{"<1990", math.MinInt, 1989, true},
+		{"<=1990", math.MinInt, 1990, true},
+		{">1990", 1991, math.MaxInt, true},
+		{">=1990", 1990, math.MaxInt, true},
+		{"1980..1970", 0, 0, false},
+		{"..", 0, 0, false},
+		{"1975s", 0, 0, false},
+		{"197", 0, 0, false},
+		{"<19x0", 0, 0, false},
+	}
+	for _, tt := range tests {
+		lo, hi, ok := parseYearRange(tt.value)
+		if ok != tt.ok || (ok && (lo != tt.lo || hi != tt.hi)) {
+			t.Errorf("parseYearRange(%q) = %d, %d, %v, want %d, %d, %v", tt.value, lo, hi, ok, tt.lo, tt.hi, tt.ok)
+		}
+	}
+}
+
+func TestSearchHandlerQueryLanguage(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		query   string
+		want    string // artist names, sorted
+		wantTyp string // when set, every result has this type
+	}{
+		{"year:1960..1969", "Pink Floyd,Scorpions", "creation"},
+		{"year:1990s", "Gorillaz,SOJA", "creation"},
+		{"year:1960..1969 location:germany", "Scorpions", ""},
+		{"year:1970s AND location:usa", "Queen", ""},
+		{"album:<1970", "Pink Floyd", "First Album"},
+		{`member:flea OR member:"damon albarn"`, "Gorillaz,Red Hot Chili Peppers", "member"},
+		{"location:germany NOT artist:scorpions", "Gorillaz,Red Hot Chili Peppers", "location"},
+		{"NOT location:germany NOT location:usa", "Pink Floyd,SOJA", "artist"},
+		{`"roger waters"`, "Pink Floyd", "member"},
+		{`"pink floyd"`, "Pink Floyd", "artist"},
+		{`"floyd pink"`, "", ""},    // quoted words keep their order
+		{`"pinc flyd"`, "", ""},     // and allow for no typos
+		{`"pink floid"`, "", ""},    // nor for names that sound alike
+		{`artist:"queeen"`, "", ""}, // in a category too
+		{`member:"freddie merc"`, "Queen", "member"},
+		{`"new york"`, "Red Hot Chili Peppers", "location"},
+		{"member:roger", "Pink Floyd,Queen", "member"},
+		{"location:berlin (year:1965 OR year:1983)", "Red Hot Chili Peppers,Scorpions", ""},
+		{"location:mars", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := searchResponse(t, "q="+url.QueryEscape(tt.query))
+			names := map[string]bool{}
+			for _, result := range resp.Results {
+				names[result.Text] = true
+				if tt.wantTyp != "" && result.Type != tt.wantTyp {
+					t.Errorf("result %+v, want type %s", result, tt.wantTyp)
+				}
+			}
+			var got []string
+			for name := range names {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("artists = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerBadQuery(t *testing.T) {
+	useFixtureCatalog(t)
+
+	w := httptest.NewRecorder()
+	SearchHandler(w, httptest.NewRequest(http.MethodGet, "/search?q="+url.QueryEscape("(queen AND pink"), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(w.Body.String(), `"error":"invalid query: missing )"`) {
+		t.Errorf("body = %s", w.Body.String())
+	}
+
+	// without operators or fields, a stray quote or parenthesis is only text
+	for _, query := range []string{"(queen", `queen "`} {
+		if resp := searchResponse(t, "q="+url.QueryEscape(query)); len(resp.Results) == 0 || resp.Results[0].Text != "Queen" {
+			t.Errorf("q=%s: %+v", query, resp.Results)
+		}
+	}
+}
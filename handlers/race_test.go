@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"tracker/src"
+)
+
+const fixtureDir = "../src/testdata/api"
+
+// useFixtureCatalog swaps Catalog for one loaded from the bundled fixtures
+// for the duration of the test.
+func useFixtureCatalog(t testing.TB) {
+	t.Helper()
+	original := Catalog
+	Catalog = src.NewCatalog(src.NewDirSource(fixtureDir), 0)
+	if err := Catalog.Load(); err != nil {
+		t.Fatalf("loading fixture catalog: %v", err)
+	}
+	t.Cleanup(func() { Catalog = original })
+}
+
+// TestConcurrentRequestsDuringRefresh hammers every data endpoint while the
+// catalog keeps reloading. Run it with -race.
+func TestConcurrentRequestsDuringRefresh(t *testing.T) {
+	t.Setenv("TEST_MODE", "true")
+	useFixtureCatalog(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/artist", ArtistHandler)
+	mux.HandleFunc("/dates", DateHandler)
+	mux.HandleFunc("/locations", LocationHandler)
+	mux.HandleFunc("/search", SearchHandler)
+
+	urls := []string{
+		"/artist?id=1",
+		"/artist?id=4",
+		"/dates?id=2",
+		"/dates?id=5",
+		"/locations?id=3",
+		"/locations?id=6",
+		"/search?q=queen",
+		"/search?q=germany",
+		"/search?q=19",
+	}
+
+	done := make(chan struct{})
+	var reloads sync.WaitGroup
+	reloads.Add(1)
+	go func() {
+		defer reloads.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if err := Catalog.Load(); err != nil {
+					t.Errorf("Load() error = %v", err)
+					return
+				}
+			}
+		}
+	}()
+
+	var requests sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		requests.Add(1)
+		go func(worker int) {
+			defer requests.Done()
+			for i := 0; i < 50; i++ {
+				url := urls[(worker+i)%len(urls)]
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("GET %s = %d, want %d", url, w.Code, http.StatusOK)
+				}
+			}
+		}(worker)
+	}
+
+	requests.Wait()
+	close(done)
+	reloads.Wait()
+}
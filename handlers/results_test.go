@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResultsHandler(t *testing.T) {
+	t.Setenv("TEST_MODE", "true")
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{"Grouped results", http.MethodGet, "/results?q=roger", http.StatusOK, "results: 2\nmember 2\n"},
+		{"Every match, not just ten", http.MethodGet, "/results?q=e&type=location", http.StatusOK, "results: 19\nlocation 19\n"},
+		{"Did you mean", http.MethodGet, "/results?q=pinc+floid", http.StatusOK, "results: 1\nartist 1\ndid you mean pink floyd\n"},
+		{"Empty query", http.MethodGet, "/results", http.StatusOK, "results: 0\n"},
+		{"Invalid query", http.MethodGet, "/results?q=%28queen+AND+pink", http.StatusOK, "results: 0\n"},
+		{"Invalid type", http.MethodGet, "/results?q=queen&type=song", http.StatusBadRequest, ""},
+		{"Invalid Path", http.MethodGet, "/results/x", http.StatusNotFound, ""},
+		{"Invalid Method", http.MethodPost, "/results?q=queen", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ResultsHandler(w, httptest.NewRequest(tt.method, tt.url, nil))
+			if w.Code != tt.expectedStatusCode {
+				t.Errorf("expected status code %d, got %d", tt.expectedStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("body %q does not contain %q", w.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
+
+func Test_groupResults(t *testing.T) {
+	groups := groupResults([]SearchResult{
+		{Type: "location", ID: 1, Score: 90},
+		{Type: "artist", ID: 2, Score: 80},
+		{Type: "location", ID: 3, Score: 70},
+	})
+	if len(groups) != 2 || groups[0].Type != "artist" || groups[1].Type != "location" {
+		t.Fatalf("groupResults() = %+v", groups)
+	}
+	if groups[1].Results[0].ID != 1 || groups[1].Results[1].ID != 3 {
+		t.Errorf("groupResults() reordered a group: %+v", groups[1].Results)
+	}
+}
+
+func Test_fragments(t *testing.T) {
+	highlights := []Highlight{
+		{Field: "context", Start: 0, End: 3},
+		{Field: "text", Start: 0, End: 3},
+		{Field: "text", Start: 6, End: 11},
+		{Field: "text", Start: 8, End: 20}, // overlaps the previous one, ignored
+	}
+	got := fragments("São <b>Paulo", "text", highlights)
+	want := []fragment{
+		{Text: "São", Match: true},
+		{Text: " <b"},
+		{Text: ">Paul", Match: true},
+		{Text: "o"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fragments() = %+v, want %+v", got, want)
+	}
+
+	if got := fragments("Queen", "context", nil); !reflect.DeepEqual(got, []fragment{{Text: "Queen"}}) {
+		t.Errorf("fragments() without highlights = %+v", got)
+	}
+}
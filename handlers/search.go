@@ -1,209 +1,489 @@
 package handlers
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
 	model "tracker/models"
+	"tracker/search"
+	"tracker/src"
 )
 
 // SearchResult with additional context field
 type SearchResult struct {
-	Type    string `json:"type"`
-	ID      int    `json:"id"`
-	Text    string `json:"text"`
-	Context string `json:"context,omitempty"` // Optional context like artist name
+	Type    string  `json:"type"`
+	ID      int     `json:"id"`
+	Text    string  `json:"text"`
+	Context string  `json:"context,omitempty"` // Optional context like artist name
+	Score   float64 `json:"score"`             // Relevance, higher is better
+
+	// Highlights are the parts of Text and Context the query matched
+	Highlights []Highlight `json:"highlights,omitempty"`
+	// Place is the location of location and concert results, whose Context
+	// shows its name
+	Place *model.Place `json:"place,omitempty"`
+}
+
+// Highlight marks the runes from Start up to End of a result's Text or
+// Context, as named by Field, as matching the query. Offsets count Unicode
+// code points, not bytes.
+type Highlight struct {
+	Field string `json:"field"` // "text" or "context"
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// highlights turns the spans search.Highlight found in a result's field into
+// highlights
+func highlights(field string, spans []search.Span) []Highlight {
+	var out []Highlight
+	for _, span := range spans {
+		out = append(out, Highlight{Field: field, Start: span.Start, End: span.End})
+	}
+	return out
 }
 
 type SearchResponse struct {
-	Success bool           `json:"success"`
-	Results []SearchResult `json:"results"`
+	Success    bool           `json:"success"`
+	Results    []SearchResult `json:"results"`
+	Total      int            `json:"total"`                // Matches before paging
+	NextCursor string         `json:"nextCursor,omitempty"` // Cursor of the next page, if any
+	Error      string         `json:"error,omitempty"`      // Why the query could not be run
+
+	// Suggestions are other spellings of a query that found few matches
+	Suggestions []string `json:"suggestions,omitempty"`
+	// Facets break down all Total matches, not just this page
+	Facets *SearchFacets `json:"facets,omitempty"`
+	// Partial is set when some categories were not searched in time
+	Partial bool `json:"partial,omitempty"`
+}
+
+// SearchAliases expands queries at search time with what their aliases
+// stand for, such as "rhcp" for "red hot chili peppers". It is nil, with no
+// aliases, until main sets it from ALIASES_FILE.
+var SearchAliases *src.AliasFile
+
+// SearchTimeout bounds how long a search may take. Categories not done by
+// then are left out and the answer is flagged as partial. main sets it from
+// SEARCH_TIMEOUT.
+var SearchTimeout = 2 * time.Second
+
+// searchScope is what one request searches: the catalog snapshot taken when
+// it started, and the aliases read then. Every category of the request
+// reads the same ones, so a refresh landing halfway never turns the index
+// hits of one snapshot into results with the artists of the next, nor
+// expands the query with two generations of aliases.
+type searchScope struct {
+	snap       *src.Snapshot
+	aliases    *search.Aliases
+	generation uint64 // Of aliases
 }
 
-// searchByType handles searching for a specific type of content
-type searchFunction func(query string) ([]SearchResult, error)
+// newSearchScope returns the scope of a request searching snap, with the
+// aliases SearchAliases currently holds
+func newSearchScope(snap *src.Snapshot) searchScope {
+	aliases, generation := SearchAliases.Current()
+	return searchScope{snap: snap, aliases: aliases, generation: generation}
+}
+
+// searchFunction searches one category. It gives up with ctx.Err() once ctx
+// is done.
+type searchFunction func(ctx context.Context, scope searchScope, query string) ([]SearchResult, error)
 
 // getArtistNameById returns artist name for a given ID
 func getArtistNameById(id int) string {
-	for _, artist := range AllArtistInfo {
-		if artist.Id == id {
-			return artist.Name
-		}
+	if artist, ok := Catalog.Snapshot().Artist(id); ok {
+		return artist.Name
 	}
 	return ""
 }
 
 // getArtistNameById returns artist name for a given ID
 func getArtistCreationbyId(id int) string {
-	for _, artist := range AllArtistInfo {
-		if artist.Id == id {
-			return strconv.Itoa(artist.CreationDate)
-		}
+	if artist, ok := Catalog.Snapshot().Artist(id); ok {
+		return strconv.Itoa(artist.CreationDate)
 	}
 	return ""
 }
 
-// searchArtists searches for artists by name
-func searchArtists(query string) ([]SearchResult, error) {
+// indexResults looks query up in the search index of the snapshot of scope
+// and turns the matching entries of field into results of the given type,
+// best first.
+func indexResults(ctx context.Context, scope searchScope, query, resultType string, field search.Field) []SearchResult {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return hitResults(scope.snap, scope.snap.Index().SearchAliases(query, scope.aliases, field), query, resultType, field)
+}
+
+// hitResults turns the hits on field of the index of snap into results of
+// the given type
+func hitResults(snap *src.Snapshot, hits []search.Hit, query, resultType string, field search.Field) []SearchResult {
 	var results []SearchResult
 
-	for _, artist := range AllArtistInfo {
-		if strings.Contains(strings.ToLower(artist.Name), strings.ToLower(query)) {
-			results = append(results, SearchResult{
-				Type: "artist",
-				ID:   artist.Id,
-				Text: artist.Name,
-			})
+	for _, hit := range hits {
+		artist, _ := snap.Artist(hit.ArtistID)
+		result := SearchResult{
+			Type:  resultType,
+			ID:    hit.ArtistID,
+			Text:  artist.Name,
+			Score: hit.Score,
 		}
-
-		if len(results) >= 10 {
-			break
+		matched := query
+		if hit.Alias != "" {
+			matched = hit.Alias
+		}
+		switch field {
+		case search.FieldName:
+			result.Highlights = highlights("text", search.Highlight(hit.Value, matched))
+		case search.FieldLocation:
+			place := snap.Place(hit.Value)
+			result.Context, result.Place = place.Name, &place
+			result.Highlights = highlights("context", search.Highlight(place.Name, matched))
+		default:
+			result.Context = hit.Value
+			result.Highlights = highlights("context", search.Highlight(hit.Value, matched))
 		}
+		results = append(results, result)
 	}
 
-	return results, nil
+	return results
+}
+
+// searchArtists searches for artists by name
+func searchArtists(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+	return indexResults(ctx, scope, query, "artist", search.FieldName), ctx.Err()
 }
 
 // searchCreations searches for creation dates
-func searchCreations(query string) ([]SearchResult, error) {
-	var results []SearchResult
+func searchCreations(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+	return indexResults(ctx, scope, query, "creation", search.FieldCreation), ctx.Err()
+}
+
+// searchFirstAlbum searches for artists by First Album
+func searchFirstAlbum(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+	return indexResults(ctx, scope, query, "First Album", search.FieldFirstAlbum), ctx.Err()
+}
+
+// searchMembers searches for artists by members
+func searchMembers(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+	return indexResults(ctx, scope, query, "member", search.FieldMember), ctx.Err()
+}
+
+// searchLocations searches the locations of both the locations and relations
+// endpoints, which the index already merges per artist
+func searchLocations(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+	return indexResults(ctx, scope, query, "location", search.FieldLocation), ctx.Err()
+}
 
-	for _, artist := range AllArtistInfo {
-		if strings.Contains(strconv.Itoa(artist.CreationDate), strings.ToLower(query)) {
+// searchConcerts searches concert dates when query is a date or a date
+// range, returning one result per artist and location with concerts in that
+// window, listing their dates
+func searchConcerts(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	from, to, ok := search.ParseDateWindow(query)
+	if !ok {
+		return nil, nil
+	}
+
+	snap := scope.snap
+	var results []SearchResult
+	byPlace := make(map[string]int) // "id location" -> position in results
+	dates := make(map[string][]string)
+
+	for _, concert := range snap.Index().ConcertsBetween(from, to) {
+		key := strconv.Itoa(concert.ArtistID) + " " + concert.Location.Key
+		if _, ok := byPlace[key]; !ok {
+			artist, _ := snap.Artist(concert.ArtistID)
+			place := snap.Place(concert.Location.Key)
+			byPlace[key] = len(results)
 			results = append(results, SearchResult{
-				Type:    "creation",
-				ID:      artist.Id,
-				Text:    artist.Name,
-				Context: strconv.Itoa(artist.CreationDate),
+				Type:  "concert",
+				ID:    concert.ArtistID,
+				Text:  artist.Name,
+				Score: search.ConcertScore,
+				Place: &place,
 			})
 		}
+		dates[key] = append(dates[key], concert.Date.Format(search.DateLayout))
+	}
 
-		if len(results) >= 10 {
-			break
-		}
+	for key, i := range byPlace {
+		results[i].Context = results[i].Place.Name + " (" + strings.Join(dates[key], ", ") + ")"
 	}
 
 	return results, nil
 }
 
-// searchFirstAlbum searches for artists by First Album
-func searchFirstAlbum(query string) ([]SearchResult, error) {
-	var results []SearchResult
+// phoneticResults returns the artists and members whose names sound like
+// query, for mode=phonetic
+func phoneticResults(ctx context.Context, scope searchScope, query string) []SearchResult {
+	if ctx.Err() != nil {
+		return nil
+	}
+	index := scope.snap.Index()
+	results := hitResults(scope.snap, index.Phonetic(query, search.FieldName), query, "artist", search.FieldName)
+	return append(results, hitResults(scope.snap, index.Phonetic(query, search.FieldMember), query, "member", search.FieldMember)...)
+}
 
-	for _, artist := range AllArtistInfo {
-		if strings.Contains(artist.FirstAlbum, strings.ToLower(query)) {
-			results = append(results, SearchResult{
-				Type:    "First Album",
-				ID:      artist.Id,
-				Text:    artist.Name,
-				Context: artist.FirstAlbum,
-			})
+// searchFuncs are the categories a free-text query runs through, in order
+var searchFuncs = []searchFunction{
+	searchArtists,
+	searchLocations,
+	searchCreations,
+	searchFirstAlbum,
+	searchMembers,
+	searchConcerts,
+}
+
+// searchAll runs query through every search category at once and returns
+// their results in the order of searchFuncs. Once ctx is done it stops
+// waiting and returns the categories that finished; the caller can tell from
+// ctx.Err() that the results are partial.
+func searchAll(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+	type found struct {
+		results []SearchResult
+		err     error
+	}
+	// buffered so that categories finishing late never block
+	done := make(chan int, len(searchFuncs))
+	slots := make([]found, len(searchFuncs))
+	for i, searchFunc := range searchFuncs {
+		go func(i int, searchFunc searchFunction) {
+			results, err := searchFunc(ctx, scope, query)
+			slots[i] = found{results, err}
+			done <- i
+		}(i, searchFunc)
+	}
+
+	finished := make([]bool, len(searchFuncs))
+wait:
+	for range searchFuncs {
+		select {
+		case i := <-done:
+			finished[i] = true
+		case <-ctx.Done():
+			break wait
 		}
+	}
 
-		if len(results) >= 10 {
-			break
+	var allResults []SearchResult
+	for i := range slots {
+		switch {
+		case !finished[i]:
+		case slots[i].err != nil && ctx.Err() != nil:
+			// the category gave up on the deadline
+		case slots[i].err != nil:
+			return nil, slots[i].err
+		default:
+			allResults = appendUnique(allResults, slots[i].results...)
 		}
 	}
 
-	return results, nil
+	return allResults, nil
 }
 
-// searchMembers searches for artists by members
-func searchMembers(query string) ([]SearchResult, error) {
-	var results []SearchResult
-
-	for _, artist := range AllArtistInfo {
-
-		for _, member := range artist.Members {
-			if strings.Contains(strings.ToLower(member), strings.ToLower(query)) {
-				results = append(results, SearchResult{
-					Type:    "member",
-					ID:      artist.Id,
-					Text:    artist.Name,
-					Context: member,
-				})
-			}
+// appendUnique appends the results not already in allResults, comparing
+// Type, ID and Context
+func appendUnique(allResults []SearchResult, results ...SearchResult) []SearchResult {
+	type resultKey struct {
+		Type    string
+		ID      int
+		Context string
+	}
+	seen := make(map[resultKey]bool, len(allResults)+len(results))
+	for _, existing := range allResults {
+		seen[resultKey{existing.Type, existing.ID, existing.Context}] = true
+	}
 
-			if len(results) >= 10 {
-				break
-			}
+	for _, result := range results {
+		key := resultKey{result.Type, result.ID, result.Context}
+		if !seen[key] {
+			seen[key] = true
+			allResults = append(allResults, result)
 		}
+	}
+	return allResults
+}
 
+// resultTypes lists every result type, in the order the results page groups them
+var resultTypes = []string{"artist", "member", "location", "concert", "creation", "First Album"}
+
+// typeKey normalises a result type or a value of the type parameter, so that
+// "First Album", "firstAlbum" and "album" name the same type
+func typeKey(t string) string {
+	key := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(t), " ", ""))
+	switch key {
+	case "album":
+		return "firstalbum"
+	case "year":
+		return "creation"
+	case "date":
+		return "concert"
 	}
+	return key
+}
 
-	return results, nil
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 100
+)
+
+// searchParams are the paging and filtering parameters of /search
+type searchParams struct {
+	types  map[string]bool // typeKey of each wanted type, nil for all
+	limit  int
+	offset int
+	mode   string // "phonetic" to match names and members by sound only
 }
 
-// searchLocations searches in both locations and relations endpoints
-func searchLocations(query string) ([]SearchResult, error) {
-	var allResults []SearchResult
+// parseSearchParams reads limit, offset or cursor, type and mode from values
+func parseSearchParams(values url.Values) (searchParams, error) {
+	params := searchParams{limit: defaultSearchLimit}
 
-	// Fetch relations data
-	resp, err := http.Get("https://groupietrackers.herokuapp.com/api/relation")
-	if err != nil {
-		return nil, err
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			return params, fmt.Errorf("limit must be a number from 1 to %d", maxSearchLimit)
+		}
+		params.limit = limit
 	}
-	defer resp.Body.Close()
 
-	var relationsData model.RootsRelation
-	if err := json.NewDecoder(resp.Body).Decode(&relationsData); err != nil {
-		return nil, err
+	if value := values.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return params, errors.New("offset must be a number of 0 or more")
+		}
+		params.offset = offset
 	}
 
-	// Search through relations data
-	for _, relation := range relationsData.Relation {
-		artistName := getArtistNameById(relation.Id)
-		for location := range relation.Places {
-			if strings.Contains(strings.ToLower(location), strings.ToLower(query)) {
-				allResults = append(allResults, SearchResult{
-					Type:    "location",
-					ID:      relation.Id,
-					Text:    artistName,
-					Context: location,
-				})
+	if value := values.Get("cursor"); value != "" {
+		offset, ok := decodeCursor(value)
+		if !ok {
+			return params, errors.New("invalid cursor")
+		}
+		params.offset = offset
+	}
+
+	if value := values.Get("type"); value != "" {
+		known := make(map[string]bool, len(resultTypes))
+		for _, t := range resultTypes {
+			known[typeKey(t)] = true
+		}
+		params.types = make(map[string]bool)
+		for _, t := range strings.Split(value, ",") {
+			key := typeKey(t)
+			if !known[key] {
+				return params, fmt.Errorf("unknown type %q", strings.TrimSpace(t))
 			}
+			params.types[key] = true
 		}
 	}
 
-	// Now fetch and search locations data
-	locResp, err := http.Get("https://groupietrackers.herokuapp.com/api/locations")
+	switch mode := values.Get("mode"); mode {
+	case "", "default":
+	case "phonetic":
+		params.mode = mode
+	default:
+		return params, fmt.Errorf("unknown mode %q", mode)
+	}
+
+	return params, nil
+}
+
+// encodeCursor turns the offset of the next page into an opaque cursor
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
 	if err != nil {
-		return nil, err
+		return 0, false
 	}
-	defer locResp.Body.Close()
+	value, found := strings.CutPrefix(string(raw), "o:")
+	offset, err := strconv.Atoi(value)
+	return offset, found && err == nil && offset >= 0
+}
 
-	var locationsData model.AllLocations
-	if err := json.NewDecoder(locResp.Body).Decode(&locationsData); err != nil {
+// rankedResults runs query in the mode of params and returns every result of
+// the wanted types, best first; equal scores keep the order the searches ran
+// in
+func rankedResults(ctx context.Context, scope searchScope, query string, params searchParams) ([]SearchResult, error) {
+	if params.mode == "phonetic" {
+		return rank(phoneticResults(ctx, scope, query), params.types), nil
+	}
+	allResults, err := runQuery(ctx, scope, query)
+	if err != nil {
 		return nil, err
 	}
+	return rank(allResults, params.types), nil
+}
 
-	// Search through locations data
-	for _, location := range locationsData.Location {
-		artistName := getArtistNameById(location.ArtistId)
-		for _, loc := range location.Locations {
-			if strings.Contains(strings.ToLower(loc), strings.ToLower(query)) {
-				// Modified duplicate check to consider the actual location (Context)
-				isDuplicate := false
-				for _, existing := range allResults {
-					if existing.ID == location.ArtistId && existing.Context == loc {
-						isDuplicate = true
-						break
-					}
-				}
-				if !isDuplicate {
-					allResults = append(allResults, SearchResult{
-						Type:    "location",
-						ID:      location.ArtistId,
-						Text:    artistName,
-						Context: loc,
-					})
-				}
-			}
+// rank keeps the results of the given types, all of them when types is nil,
+// and sorts them by score
+func rank(results []SearchResult, types map[string]bool) []SearchResult {
+	results = filterTypes(results, types)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// filterTypes returns the results of the given types, in order, or results
+// itself when types is nil
+func filterTypes(results []SearchResult, types map[string]bool) []SearchResult {
+	if types == nil {
+		return results
+	}
+	var filtered []SearchResult
+	for _, result := range results {
+		if types[typeKey(result.Type)] {
+			filtered = append(filtered, result)
 		}
 	}
+	return filtered
+}
 
-	return allResults, nil
+// fewResults is the number of matches under which a search also suggests
+// other spellings of the query
+const fewResults = 3
+
+// maxSuggestions caps the "did you mean" suggestions of a search
+const maxSuggestions = 3
+
+// didYouMean suggests spellings of a plain-text query that found fewer than
+// fewResults matches, built from the words of snap
+func didYouMean(snap *src.Snapshot, query string, found int) []string {
+	if found >= fewResults {
+		return nil
+	}
+	node, err := parseQuery(query)
+	if err != nil || node == nil || !isPlainText(node) {
+		return nil
+	}
+	return snap.Index().Suggest(query, maxSuggestions)
+}
+
+// writeSearchError replies to a search that could not be run
+func writeSearchError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(SearchResponse{
+		Success: false,
+		Results: []SearchResult{},
+		Error:   msg,
+	})
 }
 
 // SearchHandler handles the search endpoint
@@ -213,8 +493,16 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := strings.ToLower(r.URL.Query().Get("q"))
-	if query == "" {
+	w.Header().Set("Content-Type", "application/json")
+
+	params, err := parseSearchParams(r.URL.Query())
+	if err != nil {
+		writeSearchError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
 		json.NewEncoder(w).Encode(SearchResponse{
 			Success: true,
 			Results: []SearchResult{},
@@ -222,60 +510,46 @@ func SearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var allResults []SearchResult
-	var err error
-
-	searchFuncs := []searchFunction{
-		searchArtists,
-		searchLocations,
-		searchCreations,
-		searchFirstAlbum,
-		searchMembers,
-	}
-
-	// Perform all searches while respecting the result limit
-	for _, searchFunc := range searchFuncs {
-		var results []SearchResult
-		results, err = searchFunc(query)
-		if err != nil {
-			http.Error(w, "Error performing search: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Modified duplicate check to consider Type and Context
-		for _, result := range results {
-			isDuplicate := false
-			for _, existing := range allResults {
-				if existing.Type == result.Type &&
-					existing.ID == result.ID &&
-					existing.Context == result.Context {
-					isDuplicate = true
-					break
-				}
-			}
-
-			if !isDuplicate {
-				allResults = append(allResults, result)
-			}
-
-			if len(allResults) >= 10 {
-				break
-			}
-		}
+	if err := Catalog.EnsureLoaded(); err != nil {
+		http.Error(w, "Error performing search: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		if len(allResults) >= 10 {
-			break
+	// Perform all searches, or find them cached, then page through the most
+	// relevant results
+	ctx, cancel := context.WithTimeout(r.Context(), SearchTimeout)
+	defer cancel()
+	all, hit, err := cachedSearch(ctx, Catalog.Snapshot(), query, params)
+	if r.Context().Err() != nil {
+		return // the client is gone
+	}
+	if err != nil {
+		var syntaxErr *querySyntaxError
+		if errors.As(err, &syntaxErr) {
+			writeSearchError(w, http.StatusBadRequest, syntaxErr.Error())
+			return
 		}
+		http.Error(w, "Error performing search: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if params.offset == 0 {
+		SearchAnalytics.RecordSearch(query, all.Total)
+	}
+	if hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
 	}
 
-	// Limit results to 10
-	if len(allResults) > 10 {
-		allResults = allResults[:10]
+	resp := all
+	resp.Results = []SearchResult{}
+	if params.offset < all.Total {
+		end := min(params.offset+params.limit, all.Total)
+		resp.Results = all.Results[params.offset:end]
+		if end < all.Total {
+			resp.NextCursor = encodeCursor(end)
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(SearchResponse{
-		Success: true,
-		Results: allResults,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
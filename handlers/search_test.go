@@ -1,10 +1,61 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
 	"tracker/models"
+	"tracker/src"
 )
 
+// useTestCatalog swaps Catalog for one loaded from artists for the duration
+// of the test. Each artist's DateAndLocation becomes its relation and
+// location entry.
+func useTestCatalog(t *testing.T, artists []models.Data) {
+	t.Helper()
+	var (
+		list      []models.Artist
+		locations []models.Location
+		dates     []models.Date
+		relations []models.DatesLocation
+	)
+	for _, a := range artists {
+		list = append(list, models.Artist{
+			Id:           a.Id,
+			Name:         a.Name,
+			Image:        a.Image,
+			Members:      a.Members,
+			CreationDate: a.CreationDate,
+			FirstAlbum:   a.FirstAlbum,
+		})
+		location := models.Location{ArtistId: a.Id}
+		date := models.Date{Id: a.Id}
+		for place, days := range a.DateAndLocation {
+			location.Locations = append(location.Locations, place)
+			date.Dates = append(date.Dates, days...)
+		}
+		locations = append(locations, location)
+		dates = append(dates, date)
+		relations = append(relations, models.DatesLocation{Id: a.Id, Places: a.DateAndLocation})
+	}
+
+	original := Catalog
+	Catalog = src.NewCatalog(src.NewMemorySource(list, locations, dates, relations), 0)
+	if err := Catalog.Load(); err != nil {
+		t.Fatalf("loading test catalog: %v", err)
+	}
+	t.Cleanup(func() { Catalog = original })
+}
+
 func Test_getArtistNameById(t *testing.T) {
 	type args struct {
 		id int
@@ -49,7 +100,7 @@ func Test_getArtistCreationbyId(t *testing.T) {
 
 func Test_searchArtists(t *testing.T) {
 	// Set up test data
-	AllArtistInfo = []models.Data{
+	useTestCatalog(t, []models.Data{
 		{
 			Id:           1,
 			Name:         "The Beatles",
@@ -71,7 +122,7 @@ func Test_searchArtists(t *testing.T) {
 			CreationDate: 1965,
 			FirstAlbum:   "The Piper at the Gates of Dawn",
 		},
-	}
+	})
 
 	tests := []struct {
 		name    string
@@ -85,7 +136,7 @@ func Test_searchArtists(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := searchArtists(tt.query)
+			got, err := searchArtists(context.Background(), searchScope{snap: Catalog.Snapshot()}, tt.query)
 			if err != nil {
 				t.Fatalf("searchArtists() error = %v", err)
 			}
@@ -95,3 +146,456 @@ func Test_searchArtists(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkSearchHandler(b *testing.B) {
+	useFixtureCatalog(b)
+	queries := []string{"queen", "germany", "19", "freddie", "uk"}
+
+	// measure searching, not looking the same five answers up in the cache
+	original := SearchCache
+	SearchCache = NewResponseCache(0)
+	b.Cleanup(func() { SearchCache = original })
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/search?q="+queries[i%len(queries)], nil)
+		SearchHandler(httptest.NewRecorder(), req)
+	}
+}
+
+// searchResponse runs SearchHandler on the given query string and decodes the reply.
+func searchResponse(t *testing.T, rawQuery string) SearchResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	SearchHandler(w, httptest.NewRequest(http.MethodGet, "/search?"+rawQuery, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /search?%s = %d", rawQuery, w.Code)
+	}
+	var resp SearchResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return resp
+}
+
+func TestSearchHandlerRanksAcrossTypes(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		name        string
+		query       string
+		wantType    string
+		wantText    string
+		wantContext string
+	}{
+		{"typo in artist", "q=Queeen", "artist", "Queen", ""},
+		{"typo in member", "q=Freddie+Mercuy", "member", "Queen", "Freddie Mercury"},
+		{"exact artist before locations", "q=scorpions", "artist", "Scorpions", ""},
+		{"member before weaker matches", "q=flea", "member", "Red Hot Chili Peppers", "Flea"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := searchResponse(t, tt.query)
+			if len(resp.Results) == 0 {
+				t.Fatalf("no results")
+			}
+			got := resp.Results[0]
+			if got.Score <= 0 {
+				t.Errorf("first result has score %v", got.Score)
+			}
+			if got.Type != tt.wantType || got.Text != tt.wantText || got.Context != tt.wantContext {
+				t.Errorf("first result = %+v, want %s %q %q", got, tt.wantType, tt.wantText, tt.wantContext)
+			}
+			for i := 1; i < len(resp.Results); i++ {
+				if resp.Results[i].Score > resp.Results[i-1].Score {
+					t.Errorf("results not sorted by score at %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchHandlerPaging(t *testing.T) {
+	useFixtureCatalog(t)
+
+	all := searchResponse(t, "q=e&limit=100")
+	if all.Total <= 10 || len(all.Results) != all.Total || all.NextCursor != "" {
+		t.Fatalf("q=e: total %d, %d results, cursor %q", all.Total, len(all.Results), all.NextCursor)
+	}
+
+	first := searchResponse(t, "q=e")
+	if len(first.Results) != 10 || first.Total != all.Total || first.NextCursor == "" {
+		t.Fatalf("default page: %d results of %d, cursor %q", len(first.Results), first.Total, first.NextCursor)
+	}
+
+	// walking the cursors visits every result once, in order
+	var walked []SearchResult
+	for page, cursor := 0, ""; page < 100; page++ {
+		resp := searchResponse(t, "q=e&limit=7&cursor="+cursor)
+		walked = append(walked, resp.Results...)
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+	if len(walked) != all.Total {
+		t.Fatalf("cursors visited %d results, want %d", len(walked), all.Total)
+	}
+	for i := range walked {
+		if !reflect.DeepEqual(walked[i], all.Results[i]) {
+			t.Errorf("result %d = %+v, want %+v", i, walked[i], all.Results[i])
+		}
+	}
+
+	offset := searchResponse(t, "q=e&limit=5&offset=10")
+	for i, result := range offset.Results {
+		if !reflect.DeepEqual(result, all.Results[10+i]) {
+			t.Errorf("offset result %d = %+v, want %+v", i, result, all.Results[10+i])
+		}
+	}
+
+	past := searchResponse(t, "q=e&offset=1000")
+	if len(past.Results) != 0 || past.Total != all.Total {
+		t.Errorf("offset past the end: %d results of %d", len(past.Results), past.Total)
+	}
+}
+
+func TestSearchHandlerTypeFilter(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		types string
+		want  map[string]bool
+	}{
+		{"member", map[string]bool{"member": true}},
+		{"artist,location", map[string]bool{"artist": true, "location": true}},
+		{"album, year", map[string]bool{"First Album": true, "creation": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.types, func(t *testing.T) {
+			resp := searchResponse(t, "q=1&limit=100&type="+url.QueryEscape(tt.types))
+			if resp.Total != len(resp.Results) {
+				t.Errorf("total = %d, results = %d", resp.Total, len(resp.Results))
+			}
+			for _, result := range resp.Results {
+				if !tt.want[result.Type] {
+					t.Errorf("unexpected %s result %+v", result.Type, result)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchHandlerBadParams(t *testing.T) {
+	useFixtureCatalog(t)
+
+	for _, rawQuery := range []string{
+		"q=queen&limit=0",
+		"q=queen&limit=1000",
+		"q=queen&limit=ten",
+		"q=queen&offset=-1",
+		"q=queen&cursor=%25%25",
+		"q=queen&type=song",
+	} {
+		w := httptest.NewRecorder()
+		SearchHandler(w, httptest.NewRequest(http.MethodGet, "/search?"+rawQuery, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET /search?%s = %d, want %d", rawQuery, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSearchHandlerConcertDates(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		query string
+		want  []string // "artist: context"
+	}{
+		{"q=2019-05-01..2019-06-30&type=date", []string{
+			"Red Hot Chili Peppers: New York, USA (01-05-2019)",
+			"Red Hot Chili Peppers: Berlin, Germany (12-06-2019)",
+			"Red Hot Chili Peppers: London, UK (18-06-2019)",
+		}},
+		{"q=12-2019&type=concert", []string{
+			"SOJA: Playa del Carmen, Mexico (05-12-2019, 06-12-2019, 07-12-2019, 08-12-2019, 09-12-2019)",
+			"Pink Floyd: London, UK (14-12-2019)",
+		}},
+		{"q=date:2018", []string{
+			"Gorillaz: Frankfurt, Germany (11-11-2018)",
+			"Gorillaz: Birmingham, UK (08-12-2018)",
+		}},
+		{"q=queen&type=concert", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := searchResponse(t, tt.query+"&limit=100")
+			var got []string
+			for _, result := range resp.Results {
+				if result.Type != "concert" {
+					t.Errorf("unexpected %s result %+v", result.Type, result)
+				}
+				got = append(got, result.Text+": "+result.Context)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	// a bare year finds concerts alongside everything else
+	found := false
+	for _, result := range searchResponse(t, "q=2019&limit=100").Results {
+		found = found || result.Type == "concert" && result.Text == "Queen"
+	}
+	if !found {
+		t.Errorf("q=2019 has no concert of Queen")
+	}
+}
+
+func TestSearchHandlerHighlights(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		query string
+		want  string // the first result with its highlights in brackets
+	}{
+		{"q=queen", "[Queen]"},
+		{"q=mercury", "Queen / Freddie [Mercury]"},
+		{"q=Freddie+Mercuy", "Queen / [Freddie] [Mercury]"},
+		{"q=new+york", "Red Hot Chili Peppers / [New York], USA"},
+		{"q=year:1970s&type=year", "Queen / [1970]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := searchResponse(t, tt.query)
+			if len(resp.Results) == 0 {
+				t.Fatalf("no results")
+			}
+			result := resp.Results[0]
+			got := bracketed(result.Text, "text", result.Highlights)
+			if result.Context != "" {
+				got += " / " + bracketed(result.Context, "context", result.Highlights)
+			}
+			if got != tt.want {
+				t.Errorf("first result = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// bracketed renders a field of a result with its highlights in brackets
+func bracketed(text, field string, highlights []Highlight) string {
+	var b strings.Builder
+	for _, f := range fragments(text, field, highlights) {
+		if f.Match {
+			b.WriteString("[" + f.Text + "]")
+		} else {
+			b.WriteString(f.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestSearchHandlerDidYouMean(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"q=pinc+floid", []string{"pink floyd"}},
+		{"q=Scorpoins", []string{"scorpions"}},
+		{"q=gorilaz", []string{"gorillaz"}},
+		{"q=queen", nil},          // enough matches
+		{"q=zzzzzz", nil},         // nothing close
+		{"q=artist:gorilaz", nil}, // only plain text is respelled
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := searchResponse(t, tt.query).Suggestions
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("suggestions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerPhonetic(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		query string
+		want  string // type, artist and member of the first result
+	}{
+		{"q=Klows+Mayne&mode=phonetic", "member Scorpions Klaus Meine"}, // too far off for typos
+		{"q=Frediy+Merkurie", "member Queen Freddie Mercury"},           // blended into the default mode
+		{"q=Gorilas&mode=phonetic", "artist Gorillaz "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := searchResponse(t, tt.query)
+			if len(resp.Results) == 0 {
+				t.Fatalf("no results")
+			}
+			r := resp.Results[0]
+			if got := r.Type + " " + r.Text + " " + r.Context; got != tt.want {
+				t.Errorf("first result = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// phonetic mode only matches names and members by sound
+	for _, result := range searchResponse(t, "q=berlin&mode=phonetic&limit=100").Results {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// Queen and SOJA's Ken Brownell both sound KN, too short a key to blend
+	for _, result := range searchResponse(t, "q=queen&limit=100").Results {
+		if result.Text != "Queen" {
+			t.Errorf("unexpected result %+v", result)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	SearchHandler(w, httptest.NewRequest(http.MethodGet, "/search?q=queen&mode=sounds", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+// useSlowCategory adds a search category that never finishes before its
+// context ends, and shortens SearchTimeout, for the duration of the test
+func useSlowCategory(t *testing.T) {
+	t.Helper()
+	slow := func(ctx context.Context, scope searchScope, query string) ([]SearchResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	originalFuncs, originalCategories, originalTimeout, originalCache := searchFuncs, streamCategories, SearchTimeout, SearchCache
+	searchFuncs = append(append([]searchFunction{}, searchFuncs...), slow)
+	streamCategories = append(append([]streamCategory{}, streamCategories...), streamCategory{"slow", []searchFunction{slow}})
+	SearchTimeout = 50 * time.Millisecond
+	SearchCache = NewResponseCache(8)
+	t.Cleanup(func() {
+		searchFuncs, streamCategories, SearchTimeout, SearchCache = originalFuncs, originalCategories, originalTimeout, originalCache
+	})
+}
+
+func TestSearchHandlerPartial(t *testing.T) {
+	useFixtureCatalog(t)
+	useSlowCategory(t)
+
+	resp := searchResponse(t, "q=queen")
+	if !resp.Partial || !resp.Success {
+		t.Errorf("partial = %v, success = %v, want both", resp.Partial, resp.Success)
+	}
+	if len(resp.Results) == 0 || resp.Results[0].Text != "Queen" {
+		t.Errorf("results of the categories done in time = %+v", resp.Results)
+	}
+	if stats := SearchCache.Stats(); stats.Entries != 0 {
+		t.Errorf("a partial answer was cached: %+v", stats)
+	}
+}
+
+func TestSearchHandlerClientGone(t *testing.T) {
+	useFixtureCatalog(t)
+	useSlowCategory(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	SearchHandler(w, httptest.NewRequest(http.MethodGet, "/search?q=queen", nil).WithContext(ctx))
+	if w.Body.Len() != 0 {
+		t.Errorf("replied %q to a client that is gone", w.Body.String())
+	}
+}
+
+func TestSearchHandlerAliases(t *testing.T) {
+	useFixtureCatalog(t)
+	original, originalCache := SearchAliases, SearchCache
+	SearchAliases = src.NewAliasFile("../data/aliases.txt") // the dictionary shipped with the server
+	SearchCache = NewResponseCache(8)
+	t.Cleanup(func() { SearchAliases, SearchCache = original, originalCache })
+
+	tests := []struct {
+		query string
+		want  string // type, artist and context of the first result, context highlighted
+	}{
+		{"RHCP", "artist [Red Hot Chili Peppers] "},
+		{"NYC", "location Red Hot Chili Peppers [New York], USA"},
+		{"deutschland", "location Scorpions Berlin, [Germany]"},
+		{"gb", "location Pink Floyd London, [UK]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := searchResponse(t, "q="+url.QueryEscape(tt.query))
+			if len(resp.Results) == 0 {
+				t.Fatalf("no results")
+			}
+			r := resp.Results[0]
+			got := r.Type + " " + bracketed(r.Text, "text", r.Highlights) + " " + bracketed(r.Context, "context", r.Highlights)
+			if got != tt.want {
+				t.Errorf("first result = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// "de" is a word of its own, not Germany
+	for _, query := range []string{"de", "de la soul"} {
+		for _, r := range searchResponse(t, "q="+url.QueryEscape(query)+"&limit=100").Results {
+			if strings.Contains(r.Context, "Germany") {
+				t.Errorf("q=%s: %+v", query, r)
+			}
+		}
+	}
+}
+
+// TestSearchScopeOutlivesRefresh checks that a search keeps reading the
+// snapshot it started with when the catalog reloads halfway through
+func TestSearchScopeOutlivesRefresh(t *testing.T) {
+	useFixtureCatalog(t)
+	scope := searchScope{snap: Catalog.Snapshot()}
+
+	artists := []models.Artist{{Id: 1, Name: "Renamed"}}
+	Catalog = src.NewCatalog(src.NewMemorySource(artists, nil, nil, nil), 0)
+	if err := Catalog.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := searchAll(context.Background(), scope, "queen")
+	if err != nil {
+		t.Fatalf("searchAll() error = %v", err)
+	}
+	if len(results) == 0 || results[0].Type != "artist" || results[0].Text != "Queen" {
+		t.Errorf("searchAll() over the old snapshot = %+v, want Queen as it was", results)
+	}
+	if facets := facetResults(scope.snap, results); len(facets.Members) == 0 {
+		t.Errorf("facetResults() over the old snapshot = %+v", facets)
+	}
+}
+
+// TestSearchScopeKeepsAliases checks that every category of a search expands
+// the query with the aliases read when it started, even if the file changes
+func TestSearchScopeKeepsAliases(t *testing.T) {
+	useFixtureCatalog(t)
+	path := filepath.Join(t.TempDir(), "aliases.txt")
+	os.WriteFile(path, []byte("rhcp = red hot chili peppers\n"), 0o644)
+	original := SearchAliases
+	SearchAliases = src.NewAliasFile(path)
+	t.Cleanup(func() { SearchAliases = original })
+
+	scope := newSearchScope(Catalog.Snapshot())
+	os.WriteFile(path, []byte("rhcp = pink floyd\n# edited\n"), 0o644)
+	if _, generation := SearchAliases.Current(); generation == scope.generation {
+		t.Fatalf("alias file not read again")
+	}
+
+	results, err := searchArtists(context.Background(), scope, "rhcp")
+	if err != nil {
+		t.Fatalf("searchArtists() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Text != "Red Hot Chili Peppers" {
+		t.Errorf("searchArtists(rhcp) = %+v, want the aliases the search started with", results)
+	}
+}
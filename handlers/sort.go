@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	model "tracker/models"
+	"tracker/search"
+	"tracker/src"
+)
+
+// timeNow tells nextConcert sorting what "next" means; tests replace it
+var timeNow = time.Now
+
+// sortOption is an order the homepage offers
+type sortOption struct {
+	Key   string // Value of the sort parameter
+	Label string
+}
+
+// sortOptions are the orders of the homepage, in the order of its menu
+var sortOptions = []sortOption{
+	{"name", "Name"},
+	{"creation", "Creation year"},
+	{"firstAlbum", "First album"},
+	{"members", "Members"},
+	{"concertCount", "Number of concerts"},
+	{"nextConcert", "Next concert"},
+}
+
+// artistSort orders the homepage. An empty Key keeps catalog order.
+type artistSort struct {
+	Key  string
+	Desc bool
+}
+
+// parseArtistSort reads sort=<key> and order=asc|desc
+func parseArtistSort(values url.Values) (artistSort, error) {
+	s := artistSort{Key: values.Get("sort")}
+	if s.Key != "" {
+		known := false
+		for _, option := range sortOptions {
+			known = known || option.Key == s.Key
+		}
+		if !known {
+			return s, fmt.Errorf("unknown sort %q", s.Key)
+		}
+	}
+
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		s.Desc = true
+	default:
+		return s, fmt.Errorf("unknown order %q", order)
+	}
+	return s, nil
+}
+
+// sortValue is what an artist is sorted by. Artists without one, such as
+// an unparsable first album or no upcoming concert, sort last either way.
+type sortValue struct {
+	text    string
+	number  int64
+	missing bool
+}
+
+// sortValue computes the value of artist, who played concerts, for s.Key
+func (s artistSort) sortValue(artist model.Data, concerts []model.Concert, now time.Time) sortValue {
+	switch s.Key {
+	case "name":
+		return sortValue{text: search.Fold(artist.Name)}
+	case "creation":
+		return sortValue{number: int64(artist.CreationDate), missing: artist.CreationDate == 0}
+	case "firstAlbum":
+		t, err := model.ParseDate(artist.FirstAlbum)
+		return sortValue{number: t.Unix(), missing: err != nil}
+	case "members":
+		return sortValue{number: int64(len(artist.Members))}
+	case "concertCount":
+		count := 0
+		for _, concert := range concerts {
+			if concert.Valid() && !concert.Flags.Has(model.Duplicate) {
+				count++
+			}
+		}
+		return sortValue{number: int64(count)}
+	case "nextConcert":
+		// concerts are in chronological order, malformed dates last
+		for _, concert := range concerts {
+			if concert.Valid() && !concert.Date.Before(now) {
+				return sortValue{number: concert.Date.Unix()}
+			}
+		}
+		return sortValue{missing: true}
+	}
+	return sortValue{}
+}
+
+// apply sorts artists of snap in place. Ties keep name order, then catalog
+// order.
+func (s artistSort) apply(snap *src.Snapshot, artists []model.Data) {
+	if s.Key == "" {
+		return
+	}
+	now := timeNow()
+	values := make(map[int]sortValue, len(artists))
+	names := make(map[int]string, len(artists))
+	for _, artist := range artists {
+		values[artist.Id] = s.sortValue(artist, snap.ArtistConcerts(artist.Id), now)
+		names[artist.Id] = search.Fold(artist.Name)
+	}
+
+	sort.SliceStable(artists, func(i, j int) bool {
+		a, b := values[artists[i].Id], values[artists[j].Id]
+		if a.missing != b.missing {
+			return b.missing
+		}
+		if c := compareValues(a, b); c != 0 {
+			if s.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return names[artists[i].Id] < names[artists[j].Id]
+	})
+}
+
+// compareValues returns -1, 0 or 1 as a sorts before, with or after b
+func compareValues(a, b sortValue) int {
+	switch {
+	case a.text < b.text, a.text == b.text && a.number < b.number:
+		return -1
+	case a == b:
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHomepageHandlerSort(t *testing.T) {
+	t.Setenv("TEST_MODE", "true")
+	useFixtureCatalog(t)
+	timeNow = func() time.Time { return time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC) }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	tests := []struct {
+		rawQuery string
+		want     string
+	}{
+		{"sort=name", "Gorillaz,Pink Floyd,Queen,Red Hot Chili Peppers,Scorpions,SOJA"},
+		{"sort=name&order=desc", "SOJA,Scorpions,Red Hot Chili Peppers,Queen,Pink Floyd,Gorillaz"},
+		{"sort=creation", "Pink Floyd,Scorpions,Queen,Red Hot Chili Peppers,SOJA,Gorillaz"},
+		{"sort=firstAlbum", "Pink Floyd,Scorpions,Queen,Red Hot Chili Peppers,Gorillaz,SOJA"},
+		{"sort=firstAlbum&order=desc", "SOJA,Gorillaz,Red Hot Chili Peppers,Queen,Scorpions,Pink Floyd"},
+		{"sort=members", "Gorillaz,Red Hot Chili Peppers,Pink Floyd,Scorpions,Queen,SOJA"},
+		{"sort=concertCount&order=desc", "Queen,Scorpions,SOJA,Gorillaz,Pink Floyd,Red Hot Chili Peppers"},
+		// Gorillaz has no concert left and stays last either way
+		{"sort=nextConcert", "Pink Floyd,SOJA,Red Hot Chili Peppers,Queen,Scorpions,Gorillaz"},
+		{"sort=nextConcert&order=desc", "Scorpions,Queen,Red Hot Chili Peppers,SOJA,Pink Floyd,Gorillaz"},
+		{"sort=creation&members=4,5", "Pink Floyd,Scorpions,Red Hot Chili Peppers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rawQuery, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HomepageHandler(w, httptest.NewRequest(http.MethodGet, "/?"+tt.rawQuery, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET /?%s = %d", tt.rawQuery, w.Code)
+			}
+			lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")[1:]
+			if got := strings.Join(lines, ","); got != tt.want {
+				t.Errorf("artists = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"sort=age", "sort=name&order=up"} {
+		w := httptest.NewRecorder()
+		HomepageHandler(w, httptest.NewRequest(http.MethodGet, "/?"+bad, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET /?%s = %d, want %d", bad, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// streamCategory is a group of searches /search/stream reports as one event
+type streamCategory struct {
+	name  string
+	funcs []searchFunction
+}
+
+// streamCategories are the groups of a streamed plain-text search, which run
+// concurrently and are reported as each completes
+var streamCategories = []streamCategory{
+	{"artists", []searchFunction{searchArtists}},
+	{"members", []searchFunction{searchMembers}},
+	{"locations", []searchFunction{searchLocations}},
+	{"dates", []searchFunction{searchCreations, searchFirstAlbum, searchConcerts}},
+}
+
+// StreamEvent is the data of a "results" event: the best results of one
+// category and how many it found in all
+type StreamEvent struct {
+	Category string         `json:"category"`
+	Results  []SearchResult `json:"results"`
+	Total    int            `json:"total"`
+}
+
+// StreamSummary is the data of the final "summary" event
+type StreamSummary struct {
+	Total       int      `json:"total"`                 // Matches over every category
+	Suggestions []string `json:"suggestions,omitempty"` // As in SearchResponse
+	Partial     bool     `json:"partial,omitempty"`     // Some categories were not reported in time
+}
+
+// streamCategoryResults runs the searches of category and returns its
+// ranked results
+func streamCategoryResults(ctx context.Context, scope searchScope, category streamCategory, query string, types map[string]bool) (StreamEvent, error) {
+	var results []SearchResult
+	for _, searchFunc := range category.funcs {
+		found, err := searchFunc(ctx, scope, query)
+		if err != nil {
+			return StreamEvent{}, err
+		}
+		results = appendUnique(results, found...)
+	}
+	return StreamEvent{Category: category.name, Results: rank(results, types)}, nil
+}
+
+// writeEvent writes one server-sent event and flushes it to the client
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// SearchStreamHandler runs a search like SearchHandler but streams it as
+// server-sent events: a "results" event per category as soon as it is done,
+// holding its first limit results, then a "summary" event. Categories not
+// done within SearchTimeout are left out and the summary flagged as partial.
+// Queries using the query language are answered in a single "results" event
+// of category "query", and phonetic searches in one of category "phonetic".
+// Bad parameters and syntax errors get the JSON errors of /search. Searches
+// that run to their summary are logged to SearchAnalytics.
+func SearchStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wrongMethodHandler(w)
+		return
+	}
+
+	params, err := parseSearchParams(r.URL.Query())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeSearchError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	node, err := parseQuery(query)
+	var syntaxErr *querySyntaxError
+	if errors.As(err, &syntaxErr) && params.mode == "" {
+		w.Header().Set("Content-Type", "application/json")
+		writeSearchError(w, http.StatusBadRequest, syntaxErr.Error())
+		return
+	}
+
+	if err := Catalog.EnsureLoaded(); err != nil {
+		http.Error(w, "Error performing search: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	scope := newSearchScope(Catalog.Snapshot())
+
+	ctx, cancel := context.WithTimeout(r.Context(), SearchTimeout)
+	defer cancel()
+
+	// buffered so that searches finishing after the handler has returned
+	// never block
+	events := make(chan StreamEvent, len(streamCategories))
+	errs := make(chan error, len(streamCategories))
+	pending := 0
+	switch {
+	case strings.TrimSpace(query) == "":
+	case isPlainText(node) && params.mode == "":
+		folded := strings.ToLower(strings.TrimSpace(query))
+		for _, category := range streamCategories {
+			pending++
+			go func(category streamCategory) {
+				event, err := streamCategoryResults(ctx, scope, category, folded, params.types)
+				if err != nil {
+					errs <- err
+					return
+				}
+				events <- event
+			}(category)
+		}
+	default:
+		pending++
+		go func() {
+			results, err := rankedResults(ctx, scope, query, params)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if ctx.Err() != nil {
+				return // too late to be reported
+			}
+			category := "query"
+			if params.mode != "" {
+				category = params.mode
+			}
+			events <- StreamEvent{Category: category, Results: results}
+		}()
+	}
+
+	summary := StreamSummary{}
+	failed := false
+wait:
+	for ; pending > 0; pending-- {
+		select {
+		case <-ctx.Done():
+			summary.Partial = true
+			break wait
+		case event := <-events:
+			summary.Total += len(event.Results)
+			event.Total = len(event.Results)
+			event.Results = event.Results[:min(params.limit, len(event.Results))]
+			if failed {
+				continue
+			}
+			if event.Results == nil {
+				event.Results = []SearchResult{}
+			}
+			failed = writeEvent(w, "results", event) != nil
+		case err := <-errs:
+			if ctx.Err() != nil {
+				summary.Partial = true
+				break wait // the category gave up on the deadline
+			}
+			if !failed {
+				writeEvent(w, "error", SearchResponse{Results: []SearchResult{}, Error: err.Error()})
+			}
+			failed = true
+		}
+	}
+	if failed || r.Context().Err() != nil {
+		return
+	}
+
+	if strings.TrimSpace(query) != "" {
+		summary.Suggestions = didYouMean(scope.snap, query, summary.Total)
+		SearchAnalytics.RecordSearch(query, summary.Total)
+	}
+	writeEvent(w, "summary", summary)
+}
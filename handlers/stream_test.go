@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type streamedEvent struct {
+	name string
+	data string
+}
+
+// streamSearch runs SearchStreamHandler and splits its reply into events
+func streamSearch(t *testing.T, rawQuery string) []streamedEvent {
+	t.Helper()
+	w := httptest.NewRecorder()
+	SearchStreamHandler(w, httptest.NewRequest(http.MethodGet, "/search/stream?"+rawQuery, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /search/stream?%s = %d", rawQuery, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var events []streamedEvent
+	var current streamedEvent
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			events = append(events, current)
+			current = streamedEvent{}
+		}
+	}
+	return events
+}
+
+func TestSearchStreamHandler(t *testing.T) {
+	useFixtureCatalog(t)
+
+	events := streamSearch(t, "q=ro&limit=2")
+	if len(events) != len(streamCategories)+1 {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(streamCategories)+1, events)
+	}
+
+	var categories []string
+	streamed := 0
+	for _, e := range events[:len(events)-1] {
+		if e.name != "results" {
+			t.Fatalf("event %q before the summary", e.name)
+		}
+		var event StreamEvent
+		if err := json.Unmarshal([]byte(e.data), &event); err != nil {
+			t.Fatalf("decoding %s: %v", e.data, err)
+		}
+		if len(event.Results) > 2 || len(event.Results) > event.Total {
+			t.Errorf("%s: %d results of %d with limit 2", event.Category, len(event.Results), event.Total)
+		}
+		categories = append(categories, event.Category)
+		streamed += event.Total
+	}
+	sort.Strings(categories)
+	if strings.Join(categories, ",") != "artists,dates,locations,members" {
+		t.Errorf("categories = %v", categories)
+	}
+
+	last := events[len(events)-1]
+	var summary StreamSummary
+	if err := json.Unmarshal([]byte(last.data), &summary); last.name != "summary" || err != nil {
+		t.Fatalf("last event = %+v", last)
+	}
+	if want := searchResponse(t, "q=ro").Total; summary.Total != want || streamed != want {
+		t.Errorf("summary total %d, categories %d, /search total %d", summary.Total, streamed, want)
+	}
+}
+
+func TestSearchStreamHandlerQueryLanguage(t *testing.T) {
+	useFixtureCatalog(t)
+
+	events := streamSearch(t, "q=member:flea")
+	if len(events) != 2 || events[0].name != "results" || events[1].name != "summary" {
+		t.Fatalf("events = %+v", events)
+	}
+	var event StreamEvent
+	json.Unmarshal([]byte(events[0].data), &event)
+	if event.Category != "query" || event.Total != 1 || event.Results[0].Context != "Flea" {
+		t.Errorf("results event = %+v", event)
+	}
+}
+
+func TestSearchStreamHandlerErrors(t *testing.T) {
+	useFixtureCatalog(t)
+
+	for _, rawQuery := range []string{"q=%28queen+AND+pink", "q=queen&limit=0"} {
+		w := httptest.NewRecorder()
+		SearchStreamHandler(w, httptest.NewRequest(http.MethodGet, "/search/stream?"+rawQuery, nil))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"error"`) {
+			t.Errorf("GET /search/stream?%s = %d %s", rawQuery, w.Code, w.Body)
+		}
+	}
+
+	if events := streamSearch(t, "q="); len(events) != 1 || events[0].name != "summary" {
+		t.Errorf("empty query events = %+v", events)
+	}
+}
+
+func TestSearchStreamHandlerPartial(t *testing.T) {
+	useFixtureCatalog(t)
+	useSlowCategory(t)
+
+	events := streamSearch(t, "q=queen")
+	if len(events) != len(streamCategories) {
+		t.Fatalf("got %d events, want one per category done in time and a summary: %+v", len(events), events)
+	}
+	last := events[len(events)-1]
+	var summary StreamSummary
+	if err := json.Unmarshal([]byte(last.data), &summary); last.name != "summary" || err != nil {
+		t.Fatalf("last event = %+v", last)
+	}
+	if !summary.Partial || summary.Total == 0 {
+		t.Errorf("summary = %+v, want partial results", summary)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	model "tracker/models"
+	"tracker/search"
+)
+
+// Suggestion is a name, member or location offered while typing
+type Suggestion struct {
+	Type       string       `json:"type"`              // "artist", "member" or "location"
+	ID         int          `json:"id,omitempty"`      // The artist, for artists and members
+	Text       string       `json:"text"`              // Artist name, or the name of the location
+	Context    string       `json:"context,omitempty"` // The member, for members
+	Popularity int          `json:"popularity"`        // Number of concerts behind it
+	Highlights []Highlight  `json:"highlights,omitempty"`
+	Place      *model.Place `json:"place,omitempty"` // The location, for locations
+}
+
+type SuggestResponse struct {
+	Success     bool         `json:"success"`
+	Suggestions []Suggestion `json:"suggestions"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// defaultSuggestLimit is how many suggestions /suggest returns by default
+const defaultSuggestLimit = 8
+
+// suggestions completes prefix from the search index of the current
+// snapshot, most popular first
+func suggestions(prefix string, limit int) []Suggestion {
+	snap := Catalog.Snapshot()
+	out := []Suggestion{}
+
+	for _, c := range snap.Index().Complete(prefix, limit) {
+		spans := search.Highlight(c.Value, prefix)
+		s := Suggestion{ID: c.ArtistID, Popularity: c.Popularity}
+		switch c.Field {
+		case search.FieldName:
+			s.Type, s.Text = "artist", c.Value
+			s.Highlights = highlights("text", spans)
+		case search.FieldMember:
+			artist, _ := snap.Artist(c.ArtistID)
+			s.Type, s.Text, s.Context = "member", artist.Name, c.Value
+			s.Highlights = highlights("context", spans)
+		default:
+			place := snap.Place(c.Value)
+			s.Type, s.Text, s.Place = "location", place.Name, &place
+			s.Highlights = highlights("text", search.Highlight(place.Name, prefix))
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// SuggestHandler completes what is being typed in the search box: the
+// names, members and locations with a word starting with q, most popular
+// first. Submitted queries go to /search. Completions found are logged to
+// SearchAnalytics as a search for q.
+func SuggestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		wrongMethodHandler(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := defaultSuggestLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > search.MaxCompletions {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(SuggestResponse{
+				Suggestions: []Suggestion{},
+				Error:       fmt.Sprintf("limit must be between 1 and %d", search.MaxCompletions),
+			})
+			return
+		}
+		limit = n
+	}
+
+	prefix := r.URL.Query().Get("q")
+	if strings.TrimSpace(prefix) == "" {
+		json.NewEncoder(w).Encode(SuggestResponse{Success: true, Suggestions: []Suggestion{}})
+		return
+	}
+
+	if err := Catalog.EnsureLoaded(); err != nil {
+		http.Error(w, "Error performing search: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// completions are what the search box shows, so they count as a search;
+	// when there are none the box runs a streamed search, which is logged
+	// in their place
+	found := suggestions(prefix, limit)
+	if len(found) > 0 {
+		SearchAnalytics.RecordSearch(prefix, len(found))
+	}
+	json.NewEncoder(w).Encode(SuggestResponse{
+		Success:     true,
+		Suggestions: found,
+	})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func suggestResponse(t *testing.T, rawQuery string, wantStatus int) SuggestResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	SuggestHandler(w, httptest.NewRequest(http.MethodGet, "/suggest?"+rawQuery, nil))
+	if w.Code != wantStatus {
+		t.Fatalf("GET /suggest?%s = %d, want %d", rawQuery, w.Code, wantStatus)
+	}
+	var resp SuggestResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return resp
+}
+
+func TestSuggestHandler(t *testing.T) {
+	useFixtureCatalog(t)
+
+	tests := []struct {
+		query string
+		want  []string // "type id text / context (popularity)" with highlights in brackets
+	}{
+		{"q=rog", []string{
+			"member 1 Queen / [Rog]er Meddows-Taylor (8)",
+			"member 3 Pink Floyd / [Rog]er Waters (4)",
+		}},
+		{"q=Berl", []string{"location 0 [Berl]in, Germany (2)"}},
+		{"q=gor", []string{"artist 6 [Gor]illaz (4)"}},
+		{"q=s&limit=2", []string{
+			"artist 4 [S]corpions (7)",
+			"artist 2 [S]OJA (7)",
+		}},
+		{"q=zzz", nil},
+		{"q=+", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := suggestResponse(t, tt.query, http.StatusOK)
+			if !resp.Success || resp.Suggestions == nil {
+				t.Fatalf("response = %+v", resp)
+			}
+			var got []string
+			for _, s := range resp.Suggestions {
+				line := s.Type + " " + strconv.Itoa(s.ID) + " " + bracketed(s.Text, "text", s.Highlights)
+				if s.Context != "" {
+					line += " / " + bracketed(s.Context, "context", s.Highlights)
+				}
+				got = append(got, line+" ("+strconv.Itoa(s.Popularity)+")")
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSuggestHandlerBadParams(t *testing.T) {
+	useFixtureCatalog(t)
+
+	for _, rawQuery := range []string{"q=a&limit=0", "q=a&limit=17", "q=a&limit=x"} {
+		if resp := suggestResponse(t, rawQuery, http.StatusBadRequest); resp.Success || resp.Error == "" {
+			t.Errorf("GET /suggest?%s = %+v", rawQuery, resp)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	SuggestHandler(w, httptest.NewRequest(http.MethodPost, "/suggest?q=a", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /suggest = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
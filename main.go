@@ -5,8 +5,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"tracker/handlers"
+	model "tracker/models"
+	"tracker/src"
 )
 
 func main() {
@@ -17,11 +21,82 @@ func main() {
 		return
 	}
 
+	source, err := src.SourceFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	src.Source = source
+
+	ttl := 10 * time.Minute
+	if value := os.Getenv("CATALOG_TTL"); value != "" {
+		ttl, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid CATALOG_TTL: ", err)
+		}
+	}
+	handlers.Catalog = src.NewCatalog(source, ttl)
+
+	gazetteerPath := "data/gazetteer.txt"
+	if value := os.Getenv("GAZETTEER_FILE"); value != "" {
+		gazetteerPath = value
+	}
+	gazetteer, err := model.LoadGazetteer(gazetteerPath)
+	if err != nil {
+		// places are still served, without coordinates
+		log.Println("Gazetteer load error:", err)
+	}
+	handlers.Catalog.SetGazetteer(gazetteer)
+	if err := handlers.Catalog.Load(); err != nil {
+		// handlers retry the load on demand until one succeeds
+		log.Println("Catalog load error:", err)
+	}
+	go handlers.Catalog.Run(nil)
+
+	if value := os.Getenv("SEARCH_CACHE_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			log.Fatal("Invalid SEARCH_CACHE_SIZE: ", value)
+		}
+		handlers.SearchCache = handlers.NewResponseCache(size)
+	}
+	if value := os.Getenv("SEARCH_TIMEOUT"); value != "" {
+		handlers.SearchTimeout, err = time.ParseDuration(value)
+		if err != nil || handlers.SearchTimeout <= 0 {
+			log.Fatal("Invalid SEARCH_TIMEOUT: ", value)
+		}
+	}
+
+	aliasPath := "data/aliases.txt"
+	if value := os.Getenv("ALIASES_FILE"); value != "" {
+		aliasPath = value
+	}
+	handlers.SearchAliases = src.NewAliasFile(aliasPath)
+
+	logPath := "search.log"
+	if value, ok := os.LookupEnv("SEARCH_LOG"); ok {
+		logPath = value
+	}
+	if logPath != "" {
+		handlers.SearchAnalytics, err = handlers.OpenSearchLog(logPath)
+		if err != nil {
+			log.Fatal("Opening SEARCH_LOG: ", err)
+		}
+		defer handlers.SearchAnalytics.Close()
+	}
+
 	http.HandleFunc("/", handlers.HomepageHandler)
 	http.HandleFunc("/artist", handlers.ArtistHandler)
 	http.HandleFunc("/dates", handlers.DateHandler)
 	http.HandleFunc("/locations", handlers.LocationHandler)
+	http.HandleFunc("/api/locations/geo", handlers.LocationsGeoHandler)
 	http.HandleFunc("/search", handlers.SearchHandler)
+	http.HandleFunc("/search/stream", handlers.SearchStreamHandler)
+	http.HandleFunc("/search/cache", handlers.SearchCacheHandler)
+	http.HandleFunc("/search/click", handlers.SearchClickHandler)
+	http.HandleFunc("/admin/search-stats", handlers.SearchStatsHandler)
+	http.HandleFunc("/admin/concerts", handlers.ConcertReportHandler)
+	http.HandleFunc("/suggest", handlers.SuggestHandler)
+	http.HandleFunc("/results", handlers.ResultsHandler)
 	// serve the static files
 	fs := http.FileServer(http.Dir("static"))
 	http.Handle("/static/", http.StripPrefix("/static/", fs))
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DateLayout is how the API writes dates: day-month-year.
+const DateLayout = "02-01-2006"
+
+// CleanDate returns an API date without the surrounding spaces and the
+// leading '*' some of them carry.
+func CleanDate(s string) string {
+	return strings.TrimPrefix(strings.TrimSpace(s), "*")
+}
+
+// ParseDate parses an API date such as "23-08-2019", ignoring the leading
+// '*' some of them carry.
+func ParseDate(s string) (time.Time, error) {
+	return time.Parse(DateLayout, CleanDate(s))
+}
+
+// ConcertFlags note what is unusual about a concert entry.
+type ConcertFlags uint8
+
+const (
+	// Starred concerts are written with a leading '*' by the dates
+	// endpoint, which marks the first date of each location.
+	Starred ConcertFlags = 1 << iota
+	// InvalidDate concerts have a date that cannot be read; Date is zero.
+	InvalidDate
+	// UnknownCountry concerts are at a location whose country
+	// ParseLocation does not know.
+	UnknownCountry
+	// Duplicate concerts repeat an earlier date of the same artist at the
+	// same location.
+	Duplicate
+)
+
+var flagNames = []string{"starred", "invalidDate", "unknownCountry", "duplicate"}
+
+// Has reports whether every flag of flag is set in f.
+func (f ConcertFlags) Has(flag ConcertFlags) bool {
+	return f&flag == flag
+}
+
+// Names returns the names of the flags set in f, in declaration order.
+func (f ConcertFlags) Names() []string {
+	var names []string
+	for i, name := range flagNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (f ConcertFlags) String() string {
+	return strings.Join(f.Names(), ",")
+}
+
+// MarshalJSON writes f as the list of its names.
+func (f ConcertFlags) MarshalJSON() ([]byte, error) {
+	names := f.Names()
+	if names == nil {
+		names = []string{}
+	}
+	return json.Marshal(names)
+}
+
+// Concert is one show of an artist, as the relation endpoint lists it.
+type Concert struct {
+	ArtistID int          `json:"artistId"`
+	Location Place        `json:"location"`
+	Date     time.Time    `json:"date"`
+	Raw      string       `json:"raw"` // The date as the API wrote it
+	Flags    ConcertFlags `json:"flags,omitempty"`
+}
+
+// Valid reports whether c has a date to sort and compare by.
+func (c Concert) Valid() bool {
+	return !c.Flags.Has(InvalidDate)
+}
+
+// ParseConcert reads one date of the relation endpoint. Problems are
+// flagged rather than returned, so malformed entries can still be reported.
+func ParseConcert(artistID int, location, raw string) Concert {
+	c := Concert{ArtistID: artistID, Location: ParseLocation(location), Raw: raw}
+	if strings.HasPrefix(strings.TrimSpace(raw), "*") {
+		c.Flags |= Starred
+	}
+	t, err := ParseDate(raw)
+	if err != nil {
+		c.Flags |= InvalidDate
+	} else {
+		c.Date = t
+	}
+	if c.Location.Code == "" {
+		c.Flags |= UnknownCountry
+	}
+	return c
+}
+
+// ParseConcerts reads every concert of one artist from its relations, by
+// location key then in the order the API lists them. Dates repeated at a
+// location are flagged Duplicate.
+func ParseConcerts(artistID int, places DatesLocations) []Concert {
+	keys := make([]string, 0, len(places))
+	for key := range places {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var concerts []Concert
+	for _, key := range keys {
+		seen := make(map[string]bool, len(places[key]))
+		for _, raw := range places[key] {
+			c := ParseConcert(artistID, key, raw)
+			if seen[CleanDate(raw)] {
+				c.Flags |= Duplicate
+			}
+			seen[CleanDate(raw)] = true
+			concerts = append(concerts, c)
+		}
+	}
+	return concerts
+}
+
+// SortConcerts orders concerts chronologically, invalid dates last, keeping
+// the order of concerts on the same day.
+func SortConcerts(concerts []Concert) {
+	sort.SliceStable(concerts, func(i, j int) bool {
+		a, b := concerts[i], concerts[j]
+		if a.Valid() != b.Valid() {
+			return a.Valid()
+		}
+		return a.Date.Before(b.Date)
+	})
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseConcert(t *testing.T) {
+	tests := []struct {
+		location, raw string
+		date          time.Time
+		flags         ConcertFlags
+	}{
+		{"london-uk", "14-12-2019", time.Date(2019, 12, 14, 0, 0, 0, 0, time.UTC), 0},
+		{"london-uk", "*14-12-2019", time.Date(2019, 12, 14, 0, 0, 0, 0, time.UTC), Starred},
+		{"london-uk", " 01-02-2020 ", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), 0},
+		{"london-uk", "31-02-2019", time.Time{}, InvalidDate},
+		{"london-uk", "2019-12-14", time.Time{}, InvalidDate},
+		{"london-uk", "", time.Time{}, InvalidDate},
+		{"oz-emerald_land", "*TBA", time.Time{}, Starred | InvalidDate | UnknownCountry},
+	}
+	for _, tt := range tests {
+		c := ParseConcert(7, tt.location, tt.raw)
+		if c.ArtistID != 7 || c.Location != ParseLocation(tt.location) || c.Raw != tt.raw {
+			t.Errorf("ParseConcert(%q, %q) = %+v", tt.location, tt.raw, c)
+		}
+		if !c.Date.Equal(tt.date) || c.Flags != tt.flags {
+			t.Errorf("ParseConcert(%q, %q) = %v [%v], want %v [%v]", tt.location, tt.raw, c.Date, c.Flags, tt.date, tt.flags)
+		}
+		if c.Valid() == tt.flags.Has(InvalidDate) {
+			t.Errorf("ParseConcert(%q, %q).Valid() = %v", tt.location, tt.raw, c.Valid())
+		}
+	}
+}
+
+func TestParseConcerts(t *testing.T) {
+	concerts := ParseConcerts(1, DatesLocations{
+		"osaka-japan": {"28-01-2020", "28-01-2020", "bad"},
+		"london-uk":   {"14-12-2019"},
+	})
+
+	want := []struct {
+		location, raw string
+		flags         ConcertFlags
+	}{
+		{"london-uk", "14-12-2019", 0},
+		{"osaka-japan", "28-01-2020", 0},
+		{"osaka-japan", "28-01-2020", Duplicate},
+		{"osaka-japan", "bad", InvalidDate},
+	}
+	if len(concerts) != len(want) {
+		t.Fatalf("ParseConcerts() = %+v, want %d concerts", concerts, len(want))
+	}
+	for i, w := range want {
+		if c := concerts[i]; c.Location.Key != w.location || c.Raw != w.raw || c.Flags != w.flags {
+			t.Errorf("concert %d = %s %q [%v], want %s %q [%v]", i, c.Location.Key, c.Raw, c.Flags, w.location, w.raw, w.flags)
+		}
+	}
+
+	SortConcerts(concerts)
+	var order []string
+	for _, c := range concerts {
+		order = append(order, c.Raw)
+	}
+	if got := order; got[0] != "14-12-2019" || got[1] != "28-01-2020" || got[3] != "bad" {
+		t.Errorf("SortConcerts() order = %v, want chronological with invalid dates last", got)
+	}
+}
+
+func TestConcertFlagsJSON(t *testing.T) {
+	tests := []struct {
+		flags ConcertFlags
+		want  string
+	}{
+		{0, `[]`},
+		{Starred, `["starred"]`},
+		{InvalidDate | Duplicate, `["invalidDate","duplicate"]`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.flags)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("json.Marshal(%d) = %s, %v, want %s", tt.flags, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coords are a latitude and a longitude in degrees.
+type Coords struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Gazetteer gives the coordinates of location keys of the API. It is read
+// from a file shipped with the server, so geocoding never needs the network.
+// A nil Gazetteer knows no location.
+type Gazetteer struct {
+	coords map[string]Coords
+}
+
+// ParseGazetteer reads a gazetteer: one location key per line, followed by
+// its latitude and longitude, separated by commas:
+//
+//	# key, latitude, longitude
+//	los_angeles-usa, 34.0522, -118.2437
+//
+// Blank lines and lines starting with '#' are skipped.
+func ParseGazetteer(r io.Reader) (*Gazetteer, error) {
+	g := &Gazetteer{coords: make(map[string]Coords)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want key, latitude, longitude", line)
+		}
+		key := strings.ToLower(strings.TrimSpace(fields[0]))
+		lat, err1 := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		lon, err2 := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+		switch {
+		case key == "":
+			return nil, fmt.Errorf("line %d: empty key", line)
+		case err1 != nil || lat < -90 || lat > 90:
+			return nil, fmt.Errorf("line %d: invalid latitude %q", line, strings.TrimSpace(fields[1]))
+		case err2 != nil || lon < -180 || lon > 180:
+			return nil, fmt.Errorf("line %d: invalid longitude %q", line, strings.TrimSpace(fields[2]))
+		}
+		if _, ok := g.coords[key]; ok {
+			return nil, fmt.Errorf("line %d: %s listed twice", line, key)
+		}
+		g.coords[key] = Coords{Lat: lat, Lon: lon}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// LoadGazetteer reads the gazetteer kept at path.
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := ParseGazetteer(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// Len returns the number of locations g knows.
+func (g *Gazetteer) Len() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.coords)
+}
+
+// Keys returns every location key g knows, sorted.
+func (g *Gazetteer) Keys() []string {
+	if g == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(g.coords))
+	for key := range g.coords {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Locate returns the coordinates of a location key.
+func (g *Gazetteer) Locate(key string) (Coords, bool) {
+	if g == nil {
+		return Coords{}, false
+	}
+	c, ok := g.coords[strings.ToLower(strings.TrimSpace(key))]
+	return c, ok
+}
+
+// Geocode returns p with its Coords set, when g knows its key.
+func (g *Gazetteer) Geocode(p Place) Place {
+	if c, ok := g.Locate(p.Key); ok {
+		p.Coords = &c
+	}
+	return p
+}
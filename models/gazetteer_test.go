@@ -0,0 +1,71 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGazetteer(t *testing.T) {
+	g, err := ParseGazetteer(strings.NewReader(`
+# key, latitude, longitude
+london-uk, 51.5074, -0.1278
+  Osaka-Japan ,34.6937,135.5023
+`))
+	if err != nil {
+		t.Fatalf("ParseGazetteer() error = %v", err)
+	}
+	if g.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", g.Len())
+	}
+	if c, ok := g.Locate("osaka-japan"); !ok || c != (Coords{34.6937, 135.5023}) {
+		t.Errorf("Locate(osaka-japan) = %v, %v", c, ok)
+	}
+	if _, ok := g.Locate("paris-france"); ok {
+		t.Errorf("Locate(paris-france) found a key the gazetteer does not list")
+	}
+
+	p := g.Geocode(ParseLocation("london-uk"))
+	if p.Coords == nil || *p.Coords != (Coords{51.5074, -0.1278}) || p.Name != "London, UK" {
+		t.Errorf("Geocode(london-uk) = %+v", p)
+	}
+	if p := g.Geocode(ParseLocation("paris-france")); p.Coords != nil {
+		t.Errorf("Geocode(paris-france) = %+v, want no coordinates", p)
+	}
+
+	var none *Gazetteer
+	if p := none.Geocode(ParseLocation("london-uk")); p.Coords != nil || none.Len() != 0 {
+		t.Errorf("nil gazetteer located %+v", p)
+	}
+
+	for _, bad := range []string{
+		"london-uk, 51.5",
+		"london-uk, north, -0.1278",
+		"london-uk, 91, 0",
+		"london-uk, 0, 181",
+		", 0, 0",
+		"london-uk, 0, 0\nlondon-uk, 1, 1",
+	} {
+		if _, err := ParseGazetteer(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line ") {
+			t.Errorf("ParseGazetteer(%q) error = %v, want a line number", bad, err)
+		}
+	}
+}
+
+// TestShippedGazetteer checks the gazetteer of the repository against the
+// API fixture and ParseLocation.
+func TestShippedGazetteer(t *testing.T) {
+	g, err := LoadGazetteer("../data/gazetteer.txt")
+	if err != nil {
+		t.Fatalf("LoadGazetteer() error = %v", err)
+	}
+	for _, key := range fixtureKeys(t) {
+		if _, ok := g.Locate(key); !ok {
+			t.Errorf("the gazetteer does not locate %q", key)
+		}
+	}
+	for _, key := range g.Keys() {
+		if p := ParseLocation(key); p.Code == "" || p.City == "" && p.Region == "" {
+			t.Errorf("gazetteer key %q parses to %+v: every key names a known country and a city or region", key, p)
+		}
+	}
+}
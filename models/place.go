@@ -0,0 +1,205 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Place is a location key of the API, such as "north_carolina-usa", split
+// into what it names. Keys name a city or a region, then a country.
+type Place struct {
+	Key     string  `json:"key"`              // The key as the API writes it
+	City    string  `json:"city,omitempty"`   // Empty when the key names a region
+	Region  string  `json:"region,omitempty"` // State or province, when the key names one
+	Country string  `json:"country"`
+	Code    string  `json:"code,omitempty"`   // ISO 3166-1 alpha-2 code of Country, if known
+	Name    string  `json:"name"`             // For display, e.g. "North Carolina, USA"
+	Coords  *Coords `json:"coords,omitempty"` // From the Gazetteer; nil when it does not know the key
+}
+
+// country is how a country of the API is displayed, with its ISO code
+type country struct {
+	name, code string
+}
+
+// countries are the countries of the API by the last part of their keys.
+// Unknown ones are displayed from the key, without a code.
+var countries = map[string]country{
+	"argentina":            {"Argentina", "AR"},
+	"australia":            {"Australia", "AU"},
+	"austria":              {"Austria", "AT"},
+	"belarus":              {"Belarus", "BY"},
+	"belgium":              {"Belgium", "BE"},
+	"brazil":               {"Brazil", "BR"},
+	"bulgaria":             {"Bulgaria", "BG"},
+	"canada":               {"Canada", "CA"},
+	"chile":                {"Chile", "CL"},
+	"china":                {"China", "CN"},
+	"colombia":             {"Colombia", "CO"},
+	"costa_rica":           {"Costa Rica", "CR"},
+	"croatia":              {"Croatia", "HR"},
+	"czechia":              {"Czechia", "CZ"},
+	"czech_republic":       {"Czechia", "CZ"},
+	"denmark":              {"Denmark", "DK"},
+	"ecuador":              {"Ecuador", "EC"},
+	"estonia":              {"Estonia", "EE"},
+	"finland":              {"Finland", "FI"},
+	"france":               {"France", "FR"},
+	"french_polynesia":     {"French Polynesia", "PF"},
+	"germany":              {"Germany", "DE"},
+	"greece":               {"Greece", "GR"},
+	"hungary":              {"Hungary", "HU"},
+	"iceland":              {"Iceland", "IS"},
+	"india":                {"India", "IN"},
+	"indonesia":            {"Indonesia", "ID"},
+	"ireland":              {"Ireland", "IE"},
+	"israel":               {"Israel", "IL"},
+	"italy":                {"Italy", "IT"},
+	"japan":                {"Japan", "JP"},
+	"latvia":               {"Latvia", "LV"},
+	"lithuania":            {"Lithuania", "LT"},
+	"luxembourg":           {"Luxembourg", "LU"},
+	"malaysia":             {"Malaysia", "MY"},
+	"mexico":               {"Mexico", "MX"},
+	"netherlands":          {"Netherlands", "NL"},
+	"netherlands_antilles": {"Netherlands Antilles", "AN"},
+	"new_caledonia":        {"New Caledonia", "NC"},
+	"new_zealand":          {"New Zealand", "NZ"},
+	"norway":               {"Norway", "NO"},
+	"peru":                 {"Peru", "PE"},
+	"philippines":          {"Philippines", "PH"},
+	"poland":               {"Poland", "PL"},
+	"portugal":             {"Portugal", "PT"},
+	"qatar":                {"Qatar", "QA"},
+	"romania":              {"Romania", "RO"},
+	"russia":               {"Russia", "RU"},
+	"saudi_arabia":         {"Saudi Arabia", "SA"},
+	"serbia":               {"Serbia", "RS"},
+	"singapore":            {"Singapore", "SG"},
+	"slovakia":             {"Slovakia", "SK"},
+	"slovenia":             {"Slovenia", "SI"},
+	"south_africa":         {"South Africa", "ZA"},
+	"south_korea":          {"South Korea", "KR"},
+	"korea":                {"South Korea", "KR"},
+	"spain":                {"Spain", "ES"},
+	"sweden":               {"Sweden", "SE"},
+	"switzerland":          {"Switzerland", "CH"},
+	"taiwan":               {"Taiwan", "TW"},
+	"thailand":             {"Thailand", "TH"},
+	"turkey":               {"Turkey", "TR"},
+	"uk":                   {"UK", "GB"},
+	"ukraine":              {"Ukraine", "UA"},
+	"united_arab_emirates": {"United Arab Emirates", "AE"},
+	"uruguay":              {"Uruguay", "UY"},
+	"usa":                  {"USA", "US"},
+}
+
+// regions are the states and provinces that keys of each country may name
+// instead of a city
+var regions = map[string]map[string]bool{
+	"usa": setOf("alabama", "alaska", "arizona", "arkansas", "california", "colorado", "connecticut",
+		"delaware", "florida", "georgia", "hawaii", "idaho", "illinois", "indiana", "iowa", "kansas",
+		"kentucky", "louisiana", "maine", "maryland", "massachusetts", "michigan", "minnesota",
+		"mississippi", "missouri", "montana", "nebraska", "nevada", "new_hampshire", "new_jersey",
+		"new_mexico", "north_carolina", "north_dakota", "ohio", "oklahoma", "oregon", "pennsylvania",
+		"rhode_island", "south_carolina", "south_dakota", "tennessee", "texas", "utah", "vermont",
+		"virginia", "washington", "west_virginia", "wisconsin", "wyoming"),
+	"australia": setOf("new_south_wales", "queensland", "south_australia", "tasmania", "victoria",
+		"western_australia", "northern_territory"),
+	"canada": setOf("alberta", "british_columbia", "manitoba", "new_brunswick", "nova_scotia",
+		"ontario", "quebec", "saskatchewan"),
+}
+
+func setOf(items ...string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// spellings are the names keys write without their accents or with the
+// wrong case, by their part of the key
+var spellings = map[string]string{
+	"a_coruna":         "A Coruña",
+	"bogota":           "Bogotá",
+	"dusseldorf":       "Düsseldorf",
+	"malmo":            "Malmö",
+	"montreal":         "Montréal",
+	"munchen":          "München",
+	"noumea":           "Nouméa",
+	"playa_del_carmen": "Playa del Carmen",
+	"quebec":           "Québec",
+	"rio_de_janeiro":   "Rio de Janeiro",
+	"sao_paulo":        "São Paulo",
+	"zurich":           "Zürich",
+}
+
+// placeExceptions are whole keys the rules above get wrong
+var placeExceptions = map[string]Place{
+	// the API still files Willemstad under a country dissolved in 2010
+	"willemstad-netherlands_antilles": {City: "Willemstad", Country: "Curaçao", Code: "CW"},
+}
+
+// ParseLocation splits a location key of the API. Keys are lower case words
+// joined by underscores: a city or region, a dash, then a country, as in
+// "los_angeles-usa"; keys with a third part name the city, region and
+// country. A key without a dash is taken as a country.
+func ParseLocation(key string) Place {
+	key = strings.TrimSpace(key)
+	if p, ok := placeExceptions[key]; ok {
+		p.Key = key
+		p.Name = displayName(p)
+		return p
+	}
+
+	parts := strings.Split(key, "-")
+	countryKey := parts[len(parts)-1]
+	p := Place{Key: key}
+	if c, ok := countries[countryKey]; ok {
+		p.Country, p.Code = c.name, c.code
+	} else {
+		p.Country = spell(countryKey)
+	}
+
+	switch places := parts[:len(parts)-1]; len(places) {
+	case 0:
+	case 1:
+		if regions[countryKey][places[0]] {
+			p.Region = spell(places[0])
+		} else {
+			p.City = spell(places[0])
+		}
+	default:
+		p.City = spell(places[0])
+		p.Region = spell(strings.Join(places[1:], " "))
+	}
+	p.Name = displayName(p)
+	return p
+}
+
+// spell turns a part of a key into a name: "new_south_wales" into "New
+// South Wales", unless spellings knows better
+func spell(part string) string {
+	if s, ok := spellings[part]; ok {
+		return s
+	}
+	words := strings.FieldsFunc(part, func(r rune) bool { return r == '_' || r == ' ' })
+	for i, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(r)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+// displayName joins the parts of p that are set, most specific first
+func displayName(p Place) string {
+	var parts []string
+	for _, part := range []string{p.City, p.Region, p.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		key  string
+		want Place
+	}{
+		{"los_angeles-usa", Place{City: "Los Angeles", Country: "USA", Code: "US", Name: "Los Angeles, USA"}},
+		{"north_carolina-usa", Place{Region: "North Carolina", Country: "USA", Code: "US", Name: "North Carolina, USA"}},
+		{"georgia-usa", Place{Region: "Georgia", Country: "USA", Code: "US", Name: "Georgia, USA"}},
+		{"victoria-australia", Place{Region: "Victoria", Country: "Australia", Code: "AU", Name: "Victoria, Australia"}},
+		{"london-uk", Place{City: "London", Country: "UK", Code: "GB", Name: "London, UK"}},
+		{"sao_paulo-brazil", Place{City: "São Paulo", Country: "Brazil", Code: "BR", Name: "São Paulo, Brazil"}},
+		{"playa_del_carmen-mexico", Place{City: "Playa del Carmen", Country: "Mexico", Code: "MX", Name: "Playa del Carmen, Mexico"}},
+		{"noumea-new_caledonia", Place{City: "Nouméa", Country: "New Caledonia", Code: "NC", Name: "Nouméa, New Caledonia"}},
+		{"willemstad-netherlands_antilles", Place{City: "Willemstad", Country: "Curaçao", Code: "CW", Name: "Willemstad, Curaçao"}},
+		{"springfield-illinois-usa", Place{City: "Springfield", Region: "Illinois", Country: "USA", Code: "US", Name: "Springfield, Illinois, USA"}},
+		{"atlantis", Place{Country: "Atlantis", Name: "Atlantis"}},
+		{"oz-emerald_land", Place{City: "Oz", Country: "Emerald Land", Name: "Oz, Emerald Land"}},
+	}
+	for _, tt := range tests {
+		tt.want.Key = tt.key
+		if got := ParseLocation(tt.key); got != tt.want {
+			t.Errorf("ParseLocation(%q) = %+v, want %+v", tt.key, got, tt.want)
+		}
+	}
+}
+
+// fixtureKeys returns every location key of the API fixture in
+// src/testdata/api. It holds six of the 52 artists of the API, so it is a
+// sample of the keys the server meets, not all of them.
+func fixtureKeys(t *testing.T) []string {
+	t.Helper()
+	var locations AllLocations
+	var relations RootsRelation
+	for name, v := range map[string]any{"locations.json": &locations, "relation.json": &relations} {
+		body, err := os.ReadFile("../src/testdata/api/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(body, v); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, l := range locations.Location {
+		for _, key := range l.Locations {
+			seen[key] = true
+		}
+	}
+	for _, r := range relations.Relation {
+		for key := range r.Places {
+			seen[key] = true
+		}
+	}
+	var keys []string
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestParseLocationDatasetKeys parses every location key of the API, listed
+// with the place it names in testdata/location_keys.txt.
+func TestParseLocationDatasetKeys(t *testing.T) {
+	body, err := os.ReadFile("testdata/location_keys.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]bool)
+	for line, text := range strings.Split(string(body), "\n") {
+		if text = strings.TrimSpace(text); text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "|")
+		if len(fields) != 5 {
+			t.Fatalf("location_keys.txt:%d: want key | city | region | country | code", line+1)
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		key := fields[0]
+		listed[key] = true
+
+		want := Place{Key: key, City: fields[1], Region: fields[2], Country: fields[3], Code: fields[4]}
+		got := ParseLocation(key)
+		want.Name = got.Name
+		if got != want || got.City == "" && got.Region == "" {
+			t.Errorf("ParseLocation(%q) = %+v, want %+v", key, got, want)
+		}
+	}
+
+	// the list covers the gazetteer and the API fixture
+	g, err := LoadGazetteer("../data/gazetteer.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range append(g.Keys(), fixtureKeys(t)...) {
+		if !listed[key] {
+			t.Errorf("%s is missing from location_keys.txt", key)
+		}
+	}
+	if len(listed) != g.Len() {
+		t.Errorf("location_keys.txt lists %d keys, the gazetteer %d", len(listed), g.Len())
+	}
+}
@@ -0,0 +1,167 @@
+package search
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Aliases map what people type to what the catalog calls it: "rhcp" to "red
+// hot chili peppers", "nyc" to "new york", "gb" to "uk". Both sides are
+// folded, so they match however they are written. A nil Aliases has none.
+type Aliases struct {
+	expansions map[string][]string // alias -> what it stands for
+	keys       []string            // aliases, longest first
+}
+
+// ParseAliases reads an alias dictionary. Each line holds one or more
+// aliases and what they stand for, separated by commas on both sides of an
+// equals sign:
+//
+//	rhcp = red hot chili peppers
+//	gb, england, united kingdom = uk
+//
+// Blank lines and lines starting with '#' are skipped.
+func ParseAliases(r io.Reader) (*Aliases, error) {
+	a := &Aliases{expansions: make(map[string][]string)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		left, right, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '='", line)
+		}
+		aliases, expansions := foldList(left), foldList(right)
+		if len(aliases) == 0 || len(expansions) == 0 {
+			return nil, fmt.Errorf("line %d: empty side of '='", line)
+		}
+		for _, alias := range aliases {
+			for _, expansion := range expansions {
+				if expansion != alias && !contains(a.expansions[alias], expansion) {
+					a.expansions[alias] = append(a.expansions[alias], expansion)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for alias := range a.expansions {
+		a.keys = append(a.keys, alias)
+	}
+	sort.Slice(a.keys, func(i, j int) bool {
+		if len(a.keys[i]) != len(a.keys[j]) {
+			return len(a.keys[i]) > len(a.keys[j])
+		}
+		return a.keys[i] < a.keys[j]
+	})
+	return a, nil
+}
+
+// foldList folds the comma separated items of s, dropping empty ones
+func foldList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if folded := Fold(item); folded != "" {
+			out = append(out, folded)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of aliases.
+func (a *Aliases) Len() int {
+	if a == nil {
+		return 0
+	}
+	return len(a.keys)
+}
+
+// minAliasLen is the length in runes under which an alias is only expanded
+// when it is the whole query: two letter codes such as "la" are words of
+// their own in names like "De La Soul".
+const minAliasLen = 3
+
+// Expand returns the other ways of writing query, folded: query with one of
+// its aliases, a run of whole words, replaced by what it stands for. Longer
+// aliases are tried first, so "new york city" wins over "york". Aliases
+// shorter than minAliasLen only stand for something on their own.
+func (a *Aliases) Expand(query string) []string {
+	if a.Len() == 0 {
+		return nil
+	}
+	folded := Fold(query)
+	padded := " " + folded + " "
+
+	var out []string
+	for _, alias := range a.keys {
+		if utf8.RuneCountInString(alias) < minAliasLen && alias != folded {
+			continue
+		}
+		i := strings.Index(padded, " "+alias+" ")
+		if i < 0 {
+			continue
+		}
+		for _, expansion := range a.expansions[alias] {
+			expanded := strings.TrimSpace(padded[:i+1] + expansion + padded[i+1+len(alias):])
+			if expanded != folded && !contains(out, expanded) {
+				out = append(out, expanded)
+			}
+		}
+	}
+	return out
+}
+
+// aliasWeight scales the scores of hits found through an alias, so that
+// what the query says literally wins a tie.
+const aliasWeight = 0.9
+
+// SearchAliases is Search, also matching what query stands for according to
+// aliases. An entry matched through an alias scores as its match of the
+// expansion, slightly lowered, and records the expansion in Alias. Each
+// entry is returned once, with its best score.
+func (ix *Index) SearchAliases(query string, aliases *Aliases, fields ...Field) []Hit {
+	hits := ix.Search(query, fields...)
+	expansions := aliases.Expand(query)
+	if len(expansions) == 0 {
+		return hits
+	}
+
+	best := make(map[int]int, len(hits)) // entry position -> index in hits
+	for i, hit := range hits {
+		best[hit.pos] = i
+	}
+	for _, expansion := range expansions {
+		for _, hit := range ix.Search(expansion, fields...) {
+			if hit.Kind == MatchFuzzy || hit.Kind == MatchPhonetic {
+				continue // a guess about an alias is too far from the query
+			}
+			hit.Score = roundScore(hit.Score * aliasWeight)
+			hit.Alias = expansion
+			if i, ok := best[hit.pos]; !ok {
+				best[hit.pos] = len(hits)
+				hits = append(hits, hit)
+			} else if hit.Score > hits[i].Score {
+				hits[i] = hit
+			}
+		}
+	}
+	sortHits(hits)
+	return hits
+}
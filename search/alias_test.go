@@ -0,0 +1,90 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+const testAliasFile = `
+# artists
+PF, the floyd = Pink Floyd
+nc = north carolina, new caledonia
+NYC, New York City = new york
+
+gb, England = uk
+`
+
+func TestParseAliases(t *testing.T) {
+	aliases, err := ParseAliases(strings.NewReader(testAliasFile))
+	if err != nil {
+		t.Fatalf("ParseAliases() error = %v", err)
+	}
+	if aliases.Len() != 7 {
+		t.Errorf("Len() = %d, want 7", aliases.Len())
+	}
+
+	for _, bad := range []string{"rhcp red hot chili peppers", "rhcp =", " = red hot chili peppers", ", = x"} {
+		if _, err := ParseAliases(strings.NewReader("# ok\n" + bad)); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("ParseAliases(%q) error = %v, want one about line 2", bad, err)
+		}
+	}
+}
+
+func TestAliasesExpand(t *testing.T) {
+	aliases, _ := ParseAliases(strings.NewReader(testAliasFile))
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"PF", []string{"pink floyd"}},
+		{"The Floyd", []string{"pink floyd"}},
+		{"nc", []string{"north carolina", "new caledonia"}},
+		{"concerts in NYC", []string{"concerts in new york"}},
+		{"new york city", []string{"new york"}}, // longest alias first
+		{"England", []string{"uk"}},
+		{"gb", []string{"uk"}},
+		{"gb tour", nil}, // short aliases only on their own
+		{"pfx", nil},     // whole words only
+		{"floyd", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := aliases.Expand(tt.query); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Expand(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	var none *Aliases
+	if got := none.Expand("nyc"); got != nil {
+		t.Errorf("nil Aliases expanded nyc to %q", got)
+	}
+}
+
+func TestSearchAliases(t *testing.T) {
+	ix := build(testArtists, testLocations)
+	aliases, _ := ParseAliases(strings.NewReader(testAliasFile))
+
+	hits := ix.SearchAliases("pf", aliases, FieldName)
+	if len(hits) != 1 || hits[0].Value != "Pink Floyd" || hits[0].Alias != "pink floyd" {
+		t.Fatalf("SearchAliases(pf) = %+v, want Pink Floyd through its alias", hits)
+	}
+	if want := roundScore(kindScores[MatchExact] * aliasWeight); hits[0].Score != want {
+		t.Errorf("score = %v, want %v", hits[0].Score, want)
+	}
+
+	// a country alias finds every place in the country
+	hits = ix.SearchAliases("england", aliases, FieldLocation)
+	var places []string
+	for _, hit := range hits {
+		places = append(places, hit.Value)
+	}
+	if strings.Join(places, ",") != "london-uk,manchester-uk" {
+		t.Errorf("SearchAliases(england) = %q", places)
+	}
+
+	plain := ix.Search("queen")
+	if got := ix.SearchAliases("queen", nil); len(got) != len(plain) {
+		t.Errorf("SearchAliases without aliases = %+v, want %+v", got, plain)
+	}
+}
@@ -0,0 +1,111 @@
+package search
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	model "tracker/models"
+)
+
+// DateLayout is how the API writes dates: day-month-year.
+const DateLayout = model.DateLayout
+
+// ParseDate parses an API date such as "23-08-2019", ignoring the leading
+// '*' some of them carry.
+func ParseDate(s string) (time.Time, error) {
+	return model.ParseDate(s)
+}
+
+// ConcertsBetween returns the concerts on or after from and before to,
+// in chronological order.
+func (ix *Index) ConcertsBetween(from, to time.Time) []model.Concert {
+	start := sort.Search(len(ix.concerts), func(i int) bool {
+		return !ix.concerts[i].Date.Before(from)
+	})
+	end := sort.Search(len(ix.concerts), func(i int) bool {
+		return !ix.concerts[i].Date.Before(to)
+	})
+	if start >= end {
+		return nil
+	}
+	return ix.concerts[start:end]
+}
+
+// ConcertScore is the score of a concert inside a searched date window: an
+// exact match in the date field.
+var ConcertScore = kindScores[MatchExact] * fieldWeights[FieldDate]
+
+// ParseDateWindow parses a date query into the window [from, to) it covers.
+// It understands a year (2019), a month (12-2019 or 2019-12), a day
+// (01-12-2019 or 2019-12-01) and ranges of those joined by "..", which run
+// from the start of the first to the end of the second. Either end of a
+// range may be left open.
+func ParseDateWindow(query string) (from, to time.Time, ok bool) {
+	query = strings.TrimSpace(query)
+	if first, last, found := strings.Cut(query, ".."); found {
+		from, to = time.Time{}, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		if first != "" {
+			if from, _, ok = parseDatePeriod(first); !ok {
+				return time.Time{}, time.Time{}, false
+			}
+		}
+		if last != "" {
+			if _, to, ok = parseDatePeriod(last); !ok {
+				return time.Time{}, time.Time{}, false
+			}
+		}
+		return from, to, (first != "" || last != "") && from.Before(to)
+	}
+	return parseDatePeriod(query)
+}
+
+// parseDatePeriod parses a single year, month or day into [from, to).
+func parseDatePeriod(s string) (from, to time.Time, ok bool) {
+	parts := strings.FieldsFunc(strings.TrimSpace(s), func(r rune) bool {
+		return r == '-' || r == '/' || r == '.'
+	})
+
+	var nums []int
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		nums = append(nums, n)
+	}
+
+	year, month, day := 0, 0, 0
+	switch {
+	case len(parts) == 1 && len(parts[0]) == 4:
+		year = nums[0]
+	case len(parts) == 2 && len(parts[1]) == 4:
+		month, year = nums[0], nums[1]
+	case len(parts) == 2 && len(parts[0]) == 4:
+		year, month = nums[0], nums[1]
+	case len(parts) == 3 && len(parts[2]) == 4:
+		day, month, year = nums[0], nums[1], nums[2]
+	case len(parts) == 3 && len(parts[0]) == 4:
+		year, month, day = nums[0], nums[1], nums[2]
+	default:
+		return time.Time{}, time.Time{}, false
+	}
+
+	switch {
+	case month == 0:
+		from = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, 0), true
+	case month < 1 || month > 12:
+		return time.Time{}, time.Time{}, false
+	case day == 0:
+		from = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, 0), true
+	}
+
+	from = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if from.Day() != day {
+		return time.Time{}, time.Time{}, false // e.g. 31-02-2019
+	}
+	return from, from.AddDate(0, 0, 1), true
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	model "tracker/models"
+)
+
+func day(s string) time.Time {
+	t, err := ParseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseDate(t *testing.T) {
+	if got := day("*23-08-2019"); !got.Equal(time.Date(2019, 8, 23, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseDate(*23-08-2019) = %v", got)
+	}
+	if _, err := ParseDate("2019-08-23"); err == nil {
+		t.Errorf("ParseDate(2019-08-23) did not fail")
+	}
+}
+
+func TestParseDateWindow(t *testing.T) {
+	tests := []struct {
+		query    string
+		from, to string
+		ok       bool
+	}{
+		{"2019", "01-01-2019", "01-01-2020", true},
+		{"12-2019", "01-12-2019", "01-01-2020", true},
+		{"2019-12", "01-12-2019", "01-01-2020", true},
+		{"23-08-2019", "23-08-2019", "24-08-2019", true},
+		{"2019-08-23", "23-08-2019", "24-08-2019", true},
+		{"2019-05-01..2019-06-30", "01-05-2019", "01-07-2019", true},
+		{" 2019..2020 ", "01-01-2019", "01-01-2021", true},
+		{"05-2019..", "01-05-2019", "01-01-9999", true},
+		{"2020..2019", "", "", false},
+		{"..", "", "", false},
+		{"13-2019", "", "", false},
+		{"31-02-2019", "", "", false},
+		{"19", "", "", false},
+		{"queen", "", "", false},
+		{"2019..queen", "", "", false},
+	}
+	for _, tt := range tests {
+		from, to, ok := ParseDateWindow(tt.query)
+		if ok != tt.ok {
+			t.Errorf("ParseDateWindow(%q) ok = %v, want %v", tt.query, ok, tt.ok)
+			continue
+		}
+		if ok && (!from.Equal(day(tt.from)) || !to.Equal(day(tt.to))) {
+			t.Errorf("ParseDateWindow(%q) = [%v, %v), want [%s, %s)", tt.query, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+func TestConcertsBetween(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	got := ix.ConcertsBetween(day("01-01-2019"), day("01-01-2020"))
+	want := []model.Concert{
+		model.ParseConcert(1, "north_carolina-usa", "23-08-2019"),
+		model.ParseConcert(2, "london-uk", "14-12-2019"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ConcertsBetween(2019) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("concert %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	if got := ix.ConcertsBetween(day("24-08-2019"), day("14-12-2019")); len(got) != 0 {
+		t.Errorf("ConcertsBetween excluding both ends = %v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package search
+
+import "unicode"
+
+// Distance returns the optimal string alignment distance between a and b:
+// the number of rune insertions, deletions, substitutions and transpositions
+// of adjacent runes needed to turn one into the other. It gives up once the
+// distance is known to exceed max and returns max+1.
+func Distance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > max {
+		return max + 1
+	}
+	full, _ := distances(ra, rb, max, nil)
+	return full
+}
+
+// distances returns the distance from a to b and from a to the closest
+// prefix of b, both capped at max+1. It fills the classic dynamic programming
+// table one row per rune of a, keeping three rows for transpositions, and
+// never looks further into b than a prefix within max edits could reach.
+// buf is scratch space reused across calls when it is large enough.
+func distances(a, b []rune, max int, buf []int) (full, prefix int) {
+	whole := len(b) <= len(a)+max
+	if !whole {
+		b = b[:len(a)+max]
+	}
+
+	n := len(b) + 1
+	if cap(buf) < 3*n {
+		buf = make([]int, 3*n)
+	}
+	prev2, prev, curr := buf[:n], buf[n:2*n], buf[2*n:3*n]
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > max {
+			return max + 1, max + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	full, prefix = max+1, max+1
+	if whole {
+		full = min(prev[len(b)], max+1)
+	}
+	for _, d := range prev {
+		prefix = min(prefix, d)
+	}
+	return full, prefix
+}
+
+// maxEdits is how many typos a query token may contain: none for short
+// tokens and numbers, where one edit already means something else, one for
+// medium tokens and two for long ones.
+func maxEdits(token string) int {
+	n := 0
+	for _, r := range token {
+		if unicode.IsDigit(r) {
+			return 0
+		}
+		n++
+	}
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// tokenDistance reports how far query token is from word, counting a match
+// against the start of word the same as a match against all of it, so a
+// half-typed word with a typo still matches. ok is false past limit.
+func tokenDistance(token, word []rune, limit int, buf []int) (d int, ok bool) {
+	if limit == 0 || len(word)+limit < len(token) {
+		return 0, false
+	}
+	full, prefix := distances(token, word, limit, buf)
+	d = min(full, prefix)
+	return d, d <= limit
+}
+
+// commonPrefix returns the number of leading runes a and b share.
+func commonPrefix(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && ra[n] == rb[n] {
+		n++
+	}
+	return n
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
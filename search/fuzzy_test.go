@@ -0,0 +1,127 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	model "tracker/models"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"queen", "queen", 2, 0},
+		{"queeen", "queen", 2, 1},
+		{"mercuy", "mercury", 2, 1},
+		{"floid", "floyd", 2, 1},
+		{"feddrie", "freddie", 2, 2},  // one deletion, one insertion
+		{"freddei", "freddie", 2, 1},  // transposition
+		{"gorilaz", "gorillaz", 2, 1}, // missing letter
+		{"beatles", "queen", 2, 3},    // capped at max+1
+		{"a", "abcdef", 2, 3},         // length difference alone exceeds max
+		{"zürich", "zurich", 1, 1},    // runes, not bytes
+	}
+	for _, tt := range tests {
+		if got := Distance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("Distance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestTokenDistanceMatchesPrefixes(t *testing.T) {
+	tests := []struct {
+		token, word string
+		want        int
+		wantOK      bool
+	}{
+		{"mercuy", "mercury", 1, true},
+		{"mecru", "mercury", 1, true}, // half typed, with a transposition
+		{"chl", "chili", 0, false},    // too short for typos
+		{"floyd", "pink", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := tokenDistance([]rune(tt.token), []rune(tt.word), maxEdits(tt.token), nil)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("tokenDistance(%q, %q) = %d, %v, want %d, %v", tt.token, tt.word, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMaxEdits(t *testing.T) {
+	tests := map[string]int{"abc": 0, "abcd": 1, "abcdef": 1, "abcdefg": 2, "2019": 0, "12-2019": 0}
+	for token, want := range tests {
+		if got := maxEdits(token); got != want {
+			t.Errorf("maxEdits(%q) = %d, want %d", token, got, want)
+		}
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	tests := []struct {
+		query    string
+		field    Field
+		want     string
+		wantKind MatchKind
+	}{
+		{"Queeen", FieldName, "Queen", MatchFuzzy},
+		{"Freddie Mercuy", FieldMember, "Freddie Mercury", MatchFuzzy},
+		{"Pink Floid", FieldName, "Pink Floyd", MatchFuzzy},
+		{"osaka japna", FieldLocation, "osaka-japan", MatchFuzzy},
+		{"queen", FieldName, "Queen", MatchExact},
+		{"pink", FieldName, "Pink Floyd", MatchPrefix},
+		{"floyd", FieldName, "Pink Floyd", MatchWordStart},
+		{"loyd", FieldName, "Pink Floyd", MatchSubstring},
+	}
+	for _, tt := range tests {
+		hits := ix.Search(tt.query, tt.field)
+		if len(hits) == 0 {
+			t.Errorf("Search(%q) found nothing, want %q", tt.query, tt.want)
+			continue
+		}
+		if hits[0].Value != tt.want || hits[0].Kind != tt.wantKind {
+			t.Errorf("Search(%q)[0] = %q kind %d, want %q kind %d", tt.query, hits[0].Value, hits[0].Kind, tt.want, tt.wantKind)
+		}
+	}
+
+	if hits := ix.Search("1971", FieldCreation); len(hits) != 0 {
+		t.Errorf("Search(1971) = %v, numbers must not match fuzzily", hits)
+	}
+}
+
+func TestSearchRanksByRelevance(t *testing.T) {
+	ix := build([]model.Data{
+		{Id: 1, Name: "The Queens"},
+		{Id: 2, Name: "Queen"},
+		{Id: 4, Name: "Quen"},
+	}, nil)
+
+	var got []string
+	for _, hit := range ix.Search("queen", FieldName) {
+		got = append(got, hit.Value)
+	}
+	want := []string{"Queen", "The Queens", "Quen"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Search(queen) = %v, want %v", got, want)
+	}
+}
+
+func TestSearchSkipsTyposOfCommonQueries(t *testing.T) {
+	ix := build([]model.Data{
+		{Id: 1, Name: "The Queens", Members: []string{"Quen Adams"}},
+		{Id: 2, Name: "Queen"},
+		{Id: 3, Name: "Queens of the Stone Age"},
+	}, nil)
+
+	// found as typed in fewHits entries, of any field
+	for _, hit := range ix.Search("queen", FieldMember) {
+		t.Errorf("Search(queen, member) = %+v, want no typo matches", hit)
+	}
+	if hits := ix.Search("quens", FieldMember); len(hits) != 1 || hits[0].Kind != MatchFuzzy {
+		t.Errorf("Search(quens, member) = %+v, want Quen Adams as a typo", hits)
+	}
+}
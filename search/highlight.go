@@ -0,0 +1,97 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Span is a matched part of a value: its runes from Start up to, but not
+// including, End.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Highlight returns the parts of value that query matched, in order and not
+// overlapping. Offsets count runes of value as it was given, not of its
+// folded form, so "sao" highlights "São" in "São Paulo".
+//
+// A query contained in value highlights that occurrence, picked the same way
+// a hit is classified. Otherwise each query token highlights the word
+// containing it or, failing that, the word it is a typo of or sounds like.
+func Highlight(value, query string) []Span {
+	q := Fold(query)
+	if q == "" {
+		return nil
+	}
+	folded, origins := fold(value, true)
+	runes := []rune(value)
+
+	// span converts the bytes [from, to) of folded into a span of value,
+	// taking in combining marks that trail the last rune
+	span := func(from, to int) Span {
+		s := Span{Start: origins[from], End: origins[to-1] + 1}
+		for s.End < len(runes) && unicode.Is(unicode.Mn, runes[s.End]) {
+			s.End++
+		}
+		return s
+	}
+
+	if i := matchAt(folded, q); i >= 0 {
+		return []Span{span(i, i+len(q))}
+	}
+
+	var words [][2]int // byte ranges of the words of folded
+	for start := 0; start < len(folded); {
+		end := strings.IndexByte(folded[start:], ' ')
+		if end < 0 {
+			end = len(folded) - start
+		}
+		words = append(words, [2]int{start, start + end})
+		start += end + 1
+	}
+
+	var spans []Span
+	for _, token := range unique(strings.Fields(q)) {
+		tokenRunes := []rune(token)
+		limit := maxEdits(token)
+		best, bestDistance := -1, limit+1
+		found := false
+		sound := ""
+		if len(tokenRunes) >= phoneticMinLen {
+			sound = Metaphone(token)
+		}
+		for w, bounds := range words {
+			word := folded[bounds[0]:bounds[1]]
+			if i := strings.Index(word, token); i >= 0 {
+				spans = append(spans, span(bounds[0]+i, bounds[0]+i+len(token)))
+				found = true
+				break
+			}
+			if d, ok := tokenDistance(tokenRunes, []rune(word), limit, nil); ok && d < bestDistance {
+				best, bestDistance = w, d
+			} else if best < 0 && sound != "" && Metaphone(word) == sound {
+				best = w
+			}
+		}
+		if !found && best >= 0 {
+			spans = append(spans, span(words[best][0], words[best][1]))
+		}
+	}
+	return mergeSpans(spans)
+}
+
+// mergeSpans sorts spans and joins those that overlap or touch.
+func mergeSpans(spans []Span) []Span {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	var out []Span
+	for _, s := range spans {
+		if n := len(out); n > 0 && s.Start <= out[n-1].End {
+			out[n-1].End = max(out[n-1].End, s.End)
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
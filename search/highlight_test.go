@@ -0,0 +1,49 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+// marked renders value with its highlights in brackets.
+func marked(value string, spans []Span) string {
+	runes := []rune(value)
+	out, last := "", 0
+	for _, s := range spans {
+		out += string(runes[last:s.Start]) + "[" + string(runes[s.Start:s.End]) + "]"
+		last = s.End
+	}
+	return out + string(runes[last:])
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		value, query, want string
+	}{
+		{"Queen", "queen", "[Queen]"},
+		{"Queen", "QUE", "[Que]en"},
+		{"Freddie Mercury", "merc", "Freddie [Merc]ury"},
+		{"Freddie Mercury", "e", "Fr[e]ddie Mercury"},
+		{"Bananarama Banana", "banana", "[Banana]rama Banana"},
+		{"xbanana banana", "banana", "xbanana [banana]"},
+		{"north_carolina-usa", "carolina usa", "north_[carolina-usa]"},
+		{"São Paulo", "sao", "[São] Paulo"},
+		{"Beyoncé", "beyonce", "[Beyoncé]"},
+		{"Beyonce\u0301 Knowles", "beyonce", "[Beyonce\u0301] Knowles"}, // keeps the combining accent
+		{"Guns N' Roses", "n roses", "Guns [N' Roses]"},
+		{"Simon & Garfunkel", "and garf", "Simon [& Garf]unkel"},
+		{"Straße", "strasse", "[Straße]"},
+		{"Freddie Mercury", "mercury freddie", "[Freddie] [Mercury]"},
+		{"Freddie Mercury", "Freddie Mercuy", "[Freddie] [Mercury]"},
+		{"Queen", "Queeen", "[Queen]"},
+		{"Freddie Mercury", "frediy merkurie", "[Freddie] [Mercury]"}, // by sound
+		{"Queen", "zeppelin", "Queen"},
+		{"Queen", "-", "Queen"},
+	}
+	for _, tt := range tests {
+		spans := Highlight(tt.value, tt.query)
+		if got := marked(tt.value, spans); got != tt.want {
+			t.Errorf("Highlight(%q, %q) = %s (%v), want %s", tt.value, tt.query, got, fmt.Sprint(spans), tt.want)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+// Package search holds the text indexing and matching used by the /search
+// endpoint. It only depends on the models package so the catalog can build
+// an index every time it loads a snapshot.
+package search
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	model "tracker/models"
+)
+
+// Field names the artist attribute an index entry was taken from.
+type Field string
+
+const (
+	FieldName       Field = "name"
+	FieldMember     Field = "member"
+	FieldLocation   Field = "location"
+	FieldCreation   Field = "creation"
+	FieldFirstAlbum Field = "firstAlbum"
+	FieldDate       Field = "date"
+)
+
+// Entry is one indexed value: a single field of a single artist.
+type Entry struct {
+	ArtistID int
+	Field    Field
+	Value    string // the text as it came from the API
+
+	folded string // Value passed through Fold
+}
+
+// MatchKind describes how an entry matched a query, from best to worst.
+type MatchKind int
+
+const (
+	MatchExact     MatchKind = iota // the value is the query
+	MatchPrefix                     // the value starts with the query
+	MatchWordStart                  // a later word of the value starts with the query
+	MatchSubstring                  // the value contains the query
+	MatchFuzzy                      // the value matches once typos are allowed for
+	MatchPhonetic                   // the value sounds like the query
+)
+
+// Hit is an entry matching a query.
+type Hit struct {
+	Entry
+	Kind MatchKind
+	// Distance is the number of edits a fuzzy hit needed, zero otherwise.
+	Distance int
+	// Score orders hits across fields: higher is more relevant.
+	Score float64
+	// Alias is the expansion of the query the hit matched, when it was found
+	// through an alias rather than the query itself; see SearchAliases.
+	Alias string
+
+	pos int
+}
+
+func classify(value, query string) MatchKind {
+	switch i := matchAt(value, query); {
+	case value == query:
+		return MatchExact
+	case i == 0:
+		return MatchPrefix
+	case i > 0 && startsWord(value, i):
+		return MatchWordStart
+	}
+	return MatchSubstring
+}
+
+// matchAt returns where query occurs in value, preferring the start of
+// value, then the start of a later word, then its first occurrence. It
+// returns -1 if value does not contain query.
+func matchAt(value, query string) int {
+	first := strings.Index(value, query)
+	for i := first; i >= 0; {
+		if i == 0 || startsWord(value, i) {
+			return i
+		}
+		next := strings.Index(value[i+1:], query)
+		if next < 0 {
+			break
+		}
+		i += 1 + next
+	}
+	return first
+}
+
+// startsWord reports whether the byte at i > 0 begins a word of value.
+func startsWord(value string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(value[:i])
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// kindScores are the base scores of each kind of match. The gaps are wide
+// enough that field weights only reorder hits of neighbouring kinds.
+var kindScores = map[MatchKind]float64{
+	MatchExact:     100,
+	MatchPrefix:    80,
+	MatchWordStart: 65,
+	MatchSubstring: 50,
+	MatchFuzzy:     35,
+	MatchPhonetic:  25,
+}
+
+// fieldWeights scale scores by how much a match in the field says about what
+// the user is looking for: a name beats a member, which beats a place or a
+// date.
+var fieldWeights = map[Field]float64{
+	FieldName:       1,
+	FieldMember:     0.95,
+	FieldLocation:   0.85,
+	FieldCreation:   0.8,
+	FieldFirstAlbum: 0.8,
+	FieldDate:       0.75,
+}
+
+// newHit scores an entry. Fuzzy hits lose 8 points per edit, and fuzzy and
+// phonetic hits win back 2 per leading rune they share with the query, up to
+// 3, since people rarely get the start of a name wrong. Scores are rounded
+// to one decimal.
+func newHit(entry Entry, pos int, query string, kind MatchKind, distance int) Hit {
+	score := kindScores[kind]
+	if kind == MatchFuzzy || kind == MatchPhonetic {
+		score -= 8 * float64(distance)
+		score += 2 * float64(min(commonPrefix(entry.folded, query), 3))
+	}
+	score *= fieldWeights[entry.Field]
+	return Hit{
+		Entry:    entry,
+		Kind:     kind,
+		Distance: distance,
+		Score:    roundScore(score),
+		pos:      pos,
+	}
+}
+
+// roundScore rounds a score to one decimal.
+func roundScore(score float64) float64 {
+	return math.Round(score*10) / 10
+}
+
+// Index is an inverted index from tokens to entries. It is immutable once
+// built and safe for concurrent use.
+type Index struct {
+	entries  []Entry
+	postings map[string][]int // token -> ascending entry positions
+	vocab    []string         // every token, sorted
+	runes    [][]rune         // vocab as runes, for edit distances
+	grams    map[string][]int // substring of 1 to gramLen runes -> ascending vocab positions
+	sounds   map[string][]int // Metaphone key -> ascending name and member entry positions
+	concerts []model.Concert  // every dated concert, in chronological order
+	trie     *trie            // completions of names, members and locations
+}
+
+// Build indexes the names, members, locations, concert dates, creation years
+// and first album dates of artists, taking the concerts of each artist from
+// concerts, keyed by artist id, as parsed by the catalog. Entries keep the
+// order of artists, so results come back in catalog order.
+func Build(artists []model.Data, locations []model.Location, concerts map[int][]model.Concert) *Index {
+	extra := make(map[int][]string, len(locations))
+	for _, location := range locations {
+		extra[location.ArtistId] = append(extra[location.ArtistId], location.Locations...)
+	}
+
+	ix := &Index{postings: make(map[string][]int), sounds: make(map[string][]int)}
+	for _, artist := range artists {
+		ix.add(artist.Id, FieldName, artist.Name)
+		for _, member := range artist.Members {
+			ix.add(artist.Id, FieldMember, member)
+		}
+
+		seen := make(map[string]bool)
+		for _, place := range sortedKeys(artist.DateAndLocation) {
+			seen[place] = true
+			ix.add(artist.Id, FieldLocation, place)
+		}
+		for _, place := range extra[artist.Id] {
+			if !seen[place] {
+				seen[place] = true
+				ix.add(artist.Id, FieldLocation, place)
+			}
+		}
+
+		seenDates := make(map[string]bool)
+		for _, concert := range concerts[artist.Id] {
+			if !seenDates[concert.Raw] {
+				seenDates[concert.Raw] = true
+				ix.add(artist.Id, FieldDate, concert.Raw)
+			}
+			if concert.Valid() && !concert.Flags.Has(model.Duplicate) {
+				ix.concerts = append(ix.concerts, concert)
+			}
+		}
+
+		ix.add(artist.Id, FieldCreation, strconv.Itoa(artist.CreationDate))
+		ix.add(artist.Id, FieldFirstAlbum, artist.FirstAlbum)
+	}
+
+	sort.SliceStable(ix.concerts, func(i, j int) bool {
+		return ix.concerts[i].Date.Before(ix.concerts[j].Date)
+	})
+	ix.buildTrie()
+
+	ix.vocab = make([]string, 0, len(ix.postings))
+	for token := range ix.postings {
+		ix.vocab = append(ix.vocab, token)
+	}
+	sort.Strings(ix.vocab)
+	ix.runes = make([][]rune, len(ix.vocab))
+	ix.grams = make(map[string][]int)
+	for i, word := range ix.vocab {
+		ix.runes[i] = []rune(word)
+		for _, gram := range grams(ix.runes[i]) {
+			ix.grams[gram] = append(ix.grams[gram], i)
+		}
+	}
+	return ix
+}
+
+// gramLen is the length in runes of the longest substrings of words the
+// index keeps postings for.
+const gramLen = 3
+
+// grams returns the distinct substrings of word of 1 to gramLen runes.
+func grams(word []rune) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for n := 1; n <= gramLen; n++ {
+		for i := 0; i+n <= len(word); i++ {
+			if gram := string(word[i : i+n]); !seen[gram] {
+				seen[gram] = true
+				out = append(out, gram)
+			}
+		}
+	}
+	return out
+}
+
+// wordsContaining returns, in ascending order, the positions in ix.vocab of
+// the words containing token. Tokens of up to gramLen runes are looked up
+// directly; longer ones through the rarest of their grams, checking each
+// word it lists.
+func (ix *Index) wordsContaining(token string) []int {
+	runes := []rune(token)
+	if len(runes) <= gramLen {
+		return ix.grams[token]
+	}
+	var rarest []int
+	for i := 0; i+gramLen <= len(runes); i++ {
+		list, ok := ix.grams[string(runes[i:i+gramLen])]
+		if !ok {
+			return nil
+		}
+		if i == 0 || len(list) < len(rarest) {
+			rarest = list
+		}
+	}
+	var out []int
+	for _, w := range rarest {
+		if strings.Contains(ix.vocab[w], token) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// buildTrie adds every name, member and distinct location to the completion
+// trie, rating each by its number of concerts.
+func (ix *Index) buildTrie() {
+	byArtist := make(map[int]int)
+	byPlace := make(map[string]int)
+	for _, concert := range ix.concerts {
+		byArtist[concert.ArtistID]++
+		byPlace[concert.Location.Key]++
+	}
+
+	ix.trie = newTrie()
+	places := make(map[string]bool)
+	for _, entry := range ix.entries {
+		c := Completion{Field: entry.Field, ArtistID: entry.ArtistID, Value: entry.Value, Popularity: byArtist[entry.ArtistID]}
+		switch entry.Field {
+		case FieldName, FieldMember:
+			ix.trie.add(c)
+		case FieldLocation:
+			if !places[entry.Value] {
+				places[entry.Value] = true
+				c.ArtistID, c.Popularity = 0, byPlace[entry.Value]
+				ix.trie.add(c)
+			}
+		}
+	}
+}
+
+func sortedKeys(m model.DatesLocations) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (ix *Index) add(artistID int, field Field, value string) {
+	if value == "" {
+		return
+	}
+	pos := len(ix.entries)
+	ix.entries = append(ix.entries, Entry{
+		ArtistID: artistID,
+		Field:    field,
+		Value:    value,
+		folded:   Fold(value),
+	})
+
+	for _, token := range unique(Tokenize(value)) {
+		ix.postings[token] = append(ix.postings[token], pos)
+	}
+
+	if field == FieldName || field == FieldMember {
+		for _, token := range Tokenize(value) {
+			key := Metaphone(token)
+			if key == "" {
+				continue
+			}
+			if list := ix.sounds[key]; len(list) == 0 || list[len(list)-1] != pos {
+				ix.sounds[key] = append(list, pos)
+			}
+		}
+	}
+}
+
+// Tokenize folds s and splits it into words of letters and digits.
+func Tokenize(s string) []string {
+	return strings.Fields(Fold(s))
+}
+
+func unique(tokens []string) []string {
+	seen := make(map[string]bool, len(tokens))
+	out := tokens[:0]
+	for _, token := range tokens {
+		if !seen[token] {
+			seen[token] = true
+			out = append(out, token)
+		}
+	}
+	return out
+}
+
+// Len returns the number of indexed entries.
+func (ix *Index) Len() int {
+	return len(ix.entries)
+}
+
+// Lookup returns, in index order, every entry of the given fields whose value
+// contains query once both are folded. With no fields every entry is
+// considered.
+func (ix *Index) Lookup(query string, fields ...Field) []Entry {
+	var results []Entry
+	for _, pos := range ix.lookup(Fold(query), fields) {
+		results = append(results, ix.entries[pos])
+	}
+	return results
+}
+
+// lookup returns the positions of the entries of fields containing folded, a
+// query already passed through Fold.
+//
+// Every token of the query must be a substring of some token of a matching
+// entry, so candidates are gathered from the vocabulary and only those are
+// compared against the full query.
+func (ix *Index) lookup(folded string, fields []Field) []int {
+	if folded == "" {
+		return nil
+	}
+
+	var out []int
+	for _, pos := range ix.candidates(unique(strings.Fields(folded))) {
+		entry := ix.entries[pos]
+		if hasField(fields, entry.Field) && strings.Contains(entry.folded, folded) {
+			out = append(out, pos)
+		}
+	}
+	return out
+}
+
+// fewHits is the number of entries containing a query below which Search
+// also looks for typos: a query found as typed in enough places is most
+// likely spelled as meant.
+const fewHits = 3
+
+// Search returns the entries of the given fields matching query, best first.
+// Entries containing the query rank above entries that only match once
+// typos are allowed for, which rank above names and members that only sound
+// like it; see Hit.Score. Typos are only allowed for when fewer than fewHits
+// entries of any field contain the query. Sound-alikes are only looked for
+// when no entry contains it and every token has a key of blendMinKey sounds
+// or more: "queen" would otherwise bring up every "Ken", as both sound KN.
+func (ix *Index) Search(query string, fields ...Field) []Hit {
+	folded := Fold(query)
+	found := make(map[int]bool)
+	var hits []Hit
+
+	contained := ix.lookup(folded, nil)
+	for _, pos := range contained {
+		if entry := ix.entries[pos]; hasField(fields, entry.Field) {
+			found[pos] = true
+			hits = append(hits, newHit(entry, pos, folded, classify(entry.folded, folded), 0))
+		}
+	}
+
+	if tokens := unique(strings.Fields(folded)); len(tokens) > 0 && len(contained) < fewHits {
+		for pos, d := range ix.fuzzy(tokens) {
+			entry := ix.entries[pos]
+			if found[pos] || !hasField(fields, entry.Field) {
+				continue
+			}
+			found[pos] = true
+			hits = append(hits, newHit(entry, pos, folded, MatchFuzzy, d))
+		}
+	}
+
+	var keys []string
+	if len(contained) == 0 {
+		keys = blendTokens(folded)
+	}
+	for _, pos := range ix.phonetic(keys) {
+		entry := ix.entries[pos]
+		if !found[pos] && hasField(fields, entry.Field) {
+			hits = append(hits, newHit(entry, pos, folded, MatchPhonetic, 0))
+		}
+	}
+
+	sortHits(hits)
+	return hits
+}
+
+// SearchPhrase returns the entries of the given fields containing query as
+// one piece once both are folded, best first. Unlike Search it allows for
+// neither typos nor words in another order, and ignores how names sound:
+// it answers quoted phrases.
+func (ix *Index) SearchPhrase(query string, fields ...Field) []Hit {
+	folded := Fold(query)
+	var hits []Hit
+	for _, pos := range ix.lookup(folded, fields) {
+		hits = append(hits, newHit(ix.entries[pos], pos, folded, classify(ix.entries[pos].folded, folded), 0))
+	}
+	sortHits(hits)
+	return hits
+}
+
+// sortHits orders hits by score, then index order.
+func sortHits(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].pos < hits[j].pos
+	})
+}
+
+// fuzzy returns the entries in which every token matches some word within
+// that token's typo budget, mapped to the total number of edits needed.
+func (ix *Index) fuzzy(tokens []string) map[int]int {
+	matched := make([]int, len(ix.entries))
+	total := make([]int, len(ix.entries))
+
+	for i, token := range tokens {
+		limit := maxEdits(token)
+		tokenRunes := []rune(token)
+		buf := make([]int, 3*(len(tokenRunes)+limit+1))
+		best := make(map[int]int)
+		for w, word := range ix.vocab {
+			d, ok := 0, strings.Contains(word, token)
+			if !ok {
+				if d, ok = tokenDistance(tokenRunes, ix.runes[w], limit, buf); !ok {
+					continue
+				}
+			}
+			for _, pos := range ix.postings[word] {
+				if matched[pos] != i {
+					continue
+				}
+				if b, seen := best[pos]; !seen || d < b {
+					best[pos] = d
+				}
+			}
+		}
+		if len(best) == 0 {
+			return nil
+		}
+		for pos, d := range best {
+			matched[pos] = i + 1
+			total[pos] += d
+		}
+	}
+
+	out := make(map[int]int)
+	for pos, n := range matched {
+		if n == len(tokens) {
+			out[pos] = total[pos]
+		}
+	}
+	return out
+}
+
+// candidates returns, in ascending order, the entries holding a token that
+// contains each of tokens.
+func (ix *Index) candidates(tokens []string) []int {
+	// matched[pos] counts how many query tokens entry pos has satisfied so far
+	matched := make([]int, len(ix.entries))
+	for i, token := range tokens {
+		for _, w := range ix.wordsContaining(token) {
+			for _, pos := range ix.postings[ix.vocab[w]] {
+				if matched[pos] == i {
+					matched[pos] = i + 1
+				}
+			}
+		}
+	}
+
+	var out []int
+	for pos, n := range matched {
+		if n == len(tokens) {
+			out = append(out, pos)
+		}
+	}
+	return out
+}
+
+func hasField(fields []Field, field Field) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
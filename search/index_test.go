@@ -0,0 +1,293 @@
+package search
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	model "tracker/models"
+)
+
+var testArtists = []model.Data{
+	{
+		Id:           1,
+		Name:         "Queen",
+		Members:      []string{"Freddie Mercury", "Brian May"},
+		CreationDate: 1970,
+		FirstAlbum:   "14-12-1973",
+		DateAndLocation: model.DatesLocations{
+			"north_carolina-usa": {"23-08-2019"},
+			"osaka-japan":        {"28-01-2020"},
+		},
+	},
+	{
+		Id:           2,
+		Name:         "Pink Floyd",
+		Members:      []string{"Roger Waters", "David Gilmour"},
+		CreationDate: 1965,
+		FirstAlbum:   "05-08-1967",
+		DateAndLocation: model.DatesLocations{
+			"london-uk": {"14-12-2019"},
+		},
+	},
+}
+
+var testLocations = []model.Location{
+	{ArtistId: 1, Locations: []string{"north_carolina-usa", "osaka-japan"}},
+	{ArtistId: 2, Locations: []string{"london-uk", "manchester-uk"}},
+}
+
+// build indexes artists with their concerts parsed as the catalog parses them
+func build(artists []model.Data, locations []model.Location) *Index {
+	return Build(artists, locations, parseConcerts(artists))
+}
+
+func parseConcerts(artists []model.Data) map[int][]model.Concert {
+	concerts := make(map[int][]model.Concert, len(artists))
+	for _, artist := range artists {
+		list := model.ParseConcerts(artist.Id, artist.DateAndLocation)
+		model.SortConcerts(list)
+		concerts[artist.Id] = list
+	}
+	return concerts
+}
+
+func values(entries []Entry) []string {
+	var out []string
+	for _, entry := range entries {
+		out = append(out, fmt.Sprintf("%d:%s:%s", entry.ArtistID, entry.Field, entry.Value))
+	}
+	return out
+}
+
+func TestLookup(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	tests := []struct {
+		name   string
+		query  string
+		fields []Field
+		want   []string
+	}{
+		{"whole name", "queen", []Field{FieldName}, []string{"1:name:Queen"}},
+		{"case insensitive", "PINK", []Field{FieldName}, []string{"2:name:Pink Floyd"}},
+		{"inside a word", "een", []Field{FieldName}, []string{"1:name:Queen"}},
+		{"across words", "d gil", []Field{FieldMember}, []string{"2:member:David Gilmour"}},
+		{"across separators", "carolina-usa", nil, []string{"1:location:north_carolina-usa"}},
+		{"location only in locations api", "manchester", nil, []string{"2:location:manchester-uk"}},
+		{"locations are not duplicated", "uk", []Field{FieldLocation}, []string{"2:location:london-uk", "2:location:manchester-uk"}},
+		{"creation year", "197", []Field{FieldCreation}, []string{"1:creation:1970"}},
+		{"first album and concert date", "14-12", nil, []string{"1:firstAlbum:14-12-1973", "2:date:14-12-2019"}},
+		{"separators match each other", "north carolina_usa", nil, []string{"1:location:north_carolina-usa"}},
+		{"punctuation only", "_", nil, nil},
+		{"words in the wrong order", "mercury freddie", nil, nil},
+		{"no match", "zeppelin", nil, nil},
+		{"empty", "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := values(ix.Lookup(tt.query, tt.fields...))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Lookup(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWordsContaining checks the gram postings against a scan of the
+// vocabulary.
+func TestWordsContaining(t *testing.T) {
+	ix := build(benchArtists())
+	for _, token := range []string{"r", "er", "ger", "germ", "germany", "surname4", "2019", "19", "nowhere", "ermany"} {
+		var want []int
+		for w, word := range ix.vocab {
+			if strings.Contains(word, token) {
+				want = append(want, w)
+			}
+		}
+		if got := ix.wordsContaining(token); !reflect.DeepEqual(got, want) {
+			t.Errorf("wordsContaining(%q) = %v, want %v", token, got, want)
+		}
+	}
+}
+
+func TestSearchPhrase(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"pink floyd", []string{"2:name:Pink Floyd"}},
+		{"PINK  Floyd", []string{"2:name:Pink Floyd"}},
+		{"floyd pink", nil}, // words in another order
+		{"pinc flyd", nil},  // typos
+		{"pink floid", nil}, // sounds alike
+		{"freddie merc", []string{"1:member:Freddie Mercury"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, hit := range ix.SearchPhrase(tt.query, FieldName, FieldMember) {
+			got = append(got, fmt.Sprintf("%d:%s:%s", hit.ArtistID, hit.Field, hit.Value))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("SearchPhrase(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		value, query string
+		want         MatchKind
+	}{
+		{"queen", "queen", MatchExact},
+		{"queens of the stone age", "queen", MatchPrefix},
+		{"north_carolina-usa", "carolina", MatchWordStart},
+		{"roger meddows-taylor", "taylor", MatchWordStart},
+		{"bananarama banana", "banana", MatchPrefix},
+		{"xbanana banana", "banana", MatchWordStart}, // a later occurrence starts a word
+		{"pink floyd", "loyd", MatchSubstring},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.value, tt.query); got != tt.want {
+			t.Errorf("classify(%q, %q) = %d, want %d", tt.value, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestScoresFollowKindThenField(t *testing.T) {
+	ix := build(testArtists, testLocations)
+	score := func(query string, field Field) float64 {
+		hits := ix.Search(query, field)
+		if len(hits) == 0 {
+			t.Fatalf("Search(%q, %s) found nothing", query, field)
+		}
+		return hits[0].Score
+	}
+
+	ordered := []struct {
+		query string
+		field Field
+	}{
+		{"queen", FieldName},            // exact name
+		{"london-uk", FieldLocation},    // exact location
+		{"freddie", FieldMember},        // prefix member
+		{"mercury", FieldMember},        // word start member
+		{"ercury", FieldMember},         // substring member
+		{"freddie mercuy", FieldMember}, // fuzzy member
+	}
+	for i := 1; i < len(ordered); i++ {
+		prev, next := ordered[i-1], ordered[i]
+		if a, b := score(prev.query, prev.field), score(next.query, next.field); a <= b {
+			t.Errorf("%q in %s scored %v, not above %q in %s at %v", prev.query, prev.field, a, next.query, next.field, b)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Roger Meddows-Taylor, north_carolina-USA 14-12-1973")
+	want := []string{"roger", "meddows", "taylor", "north", "carolina", "usa", "14", "12", "1973"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+// benchArtists builds a catalog the size of the real API: 52 artists with a
+// handful of members and a few dozen concerts each.
+func benchArtists() ([]model.Data, []model.Location) {
+	cities := []string{"london-uk", "berlin-germany", "sao_paulo-brazil", "north_carolina-usa", "osaka-japan",
+		"playa_del_carmen-mexico", "dunedin-new_zealand", "aarhus-denmark", "los_angeles-usa", "paris-france"}
+
+	var artists []model.Data
+	var locations []model.Location
+	for id := 1; id <= 52; id++ {
+		artist := model.Data{
+			Id:              id,
+			Name:            "Artist " + strconv.Itoa(id),
+			CreationDate:    1950 + id,
+			FirstAlbum:      fmt.Sprintf("%02d-%02d-%d", id%28+1, id%12+1, 1960+id),
+			DateAndLocation: model.DatesLocations{},
+		}
+		location := model.Location{ArtistId: id}
+		for m := 0; m < 5; m++ {
+			artist.Members = append(artist.Members, fmt.Sprintf("Member%d Surname%d", m, id))
+		}
+		for c := 0; c < 30; c++ {
+			place := strings.Replace(cities[c%len(cities)], "-", fmt.Sprintf("_%d-", c), 1)
+			artist.DateAndLocation[place] = []string{fmt.Sprintf("%02d-%02d-2019", c%28+1, id%12+1)}
+			location.Locations = append(location.Locations, place)
+		}
+		artists = append(artists, artist)
+		locations = append(locations, location)
+	}
+	return artists, locations
+}
+
+func BenchmarkBuild(b *testing.B) {
+	artists, locations := benchArtists()
+	concerts := parseConcerts(artists)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Build(artists, locations, concerts)
+	}
+}
+
+// BenchmarkLinearScan is the scan /search used to run before the index, over
+// the same data and queries as BenchmarkSearch.
+func BenchmarkLinearScan(b *testing.B) {
+	artists, _ := benchArtists()
+	scan := func(query string) (n int) {
+		for _, artist := range artists {
+			values := append([]string{artist.Name, strconv.Itoa(artist.CreationDate), artist.FirstAlbum}, artist.Members...)
+			for place, dates := range artist.DateAndLocation {
+				values = append(values, place)
+				values = append(values, dates...)
+			}
+			for _, value := range values {
+				if strings.Contains(strings.ToLower(value), query) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scan("germany")
+		scan("surname4")
+		scan("2019")
+	}
+}
+
+// BenchmarkSearch runs the queries of BenchmarkLinearScan the way /search
+// does: once per category, through SearchAliases.
+func BenchmarkSearch(b *testing.B) {
+	ix := build(benchArtists())
+	fields := []Field{FieldName, FieldMember, FieldLocation, FieldCreation, FieldFirstAlbum}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, query := range []string{"germany", "surname4", "2019"} {
+			for _, field := range fields {
+				ix.SearchAliases(query, nil, field)
+			}
+		}
+	}
+}
+
+// BenchmarkSearchTypos runs queries found nowhere as typed, which Search
+// still looks up among every word of the index allowing for typos.
+func BenchmarkSearchTypos(b *testing.B) {
+	ix := build(benchArtists())
+	fields := []Field{FieldName, FieldMember, FieldLocation, FieldCreation, FieldFirstAlbum}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, query := range []string{"germny", "berlln", "surnme"} {
+			for _, field := range fields {
+				ix.SearchAliases(query, nil, field)
+			}
+		}
+	}
+}
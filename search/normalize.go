@@ -0,0 +1,113 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Fold normalizes s for matching, so that values and queries written
+// differently compare equal:
+//
+//   - letters are lowercased and stripped of accents, both precomposed (é)
+//     and decomposed (e followed by a combining acute), and ligatures such as
+//     ß and æ are spelled out;
+//   - apostrophes are dropped, so "Guns N' Roses" reads "guns n roses";
+//   - & becomes the word "and";
+//   - any other run of punctuation, spaces, underscores or hyphens becomes a
+//     single space, with none left at either end.
+//
+// "São Paulo", "sao_paulo" and "SAO-PAULO" all fold to "sao paulo".
+func Fold(s string) string {
+	folded, _ := fold(s, false)
+	return folded
+}
+
+// fold implements Fold. With track set it also returns, for every byte of
+// the folded string, the index of the rune of s it came from.
+func fold(s string, track bool) (string, []int) {
+	var b strings.Builder
+	b.Grow(len(s))
+	var origins []int
+	gap := false // a separator is due before the next word character
+
+	i := 0 // rune index in s
+	write := func(text string) {
+		if gap && b.Len() > 0 {
+			b.WriteByte(' ')
+			if track {
+				origins = append(origins, i)
+			}
+		}
+		gap = false
+		b.WriteString(text)
+		if track {
+			for range len(text) {
+				origins = append(origins, i)
+			}
+		}
+	}
+
+	for _, r := range s {
+		r = unicode.ToLower(r)
+		switch {
+		case unicode.Is(unicode.Mn, r), r == '\'', r == '’', r == 'ʼ':
+			// combining marks left over from decomposed text, apostrophes
+		case r == '&':
+			gap = true
+			write("and")
+			gap = true
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if base, ok := foldedRunes[r]; ok {
+				write(base)
+			} else {
+				write(string(r))
+			}
+		default:
+			gap = true
+		}
+		i++
+	}
+	return b.String(), origins
+}
+
+// decompositions lists, for each plain spelling, the lowercase letters that
+// fold to it: Latin-1, Latin Extended-A and the more common Extended-B and
+// Vietnamese letters. It is what Unicode canonical decomposition followed by
+// dropping the marks gives for these, without pulling in the full tables.
+var decompositions = map[string]string{
+	"a":  "àáâãäåāăąǎǟǡǻȁȃȧạảấầẩẫậắằẳẵặ",
+	"ae": "æǣǽ",
+	"c":  "çćĉċč",
+	"d":  "ďđð",
+	"e":  "èéêëēĕėęěȅȇȩẹẻẽếềểễệ",
+	"g":  "ĝğġģǧǵ",
+	"h":  "ĥħȟ",
+	"i":  "ìíîïĩīĭįıǐȉȋỉị",
+	"ij": "ĳ",
+	"j":  "ĵǰ",
+	"k":  "ķǩ",
+	"l":  "ĺļľŀł",
+	"n":  "ñńņňŉǹ",
+	"o":  "òóôõöøōŏőơǒǫǭǿȍȏȫȭȯȱọỏốồổỗộớờởỡợ",
+	"oe": "œ",
+	"r":  "ŕŗřȑȓ",
+	"s":  "śŝşšș",
+	"ss": "ß",
+	"t":  "ţťŧț",
+	"th": "þ",
+	"u":  "ùúûüũūŭůűųưǔǖǘǚǜȕȗụủứừửữự",
+	"w":  "ŵẁẃẅ",
+	"y":  "ýÿŷȳỳỵỷỹ",
+	"z":  "źżžƶ",
+}
+
+// foldedRunes maps every rune of decompositions to its plain spelling.
+var foldedRunes = func() map[rune]string {
+	m := make(map[rune]string)
+	for plain, runes := range decompositions {
+		for _, r := range runes {
+			m[r] = plain
+		}
+	}
+	return m
+}()
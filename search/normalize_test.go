@@ -0,0 +1,70 @@
+package search
+
+import (
+	"testing"
+
+	model "tracker/models"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Beyoncé", "beyonce"},
+		{"Beyonce\u0301", "beyonce"}, // decomposed: e + combining acute
+		{"Mötley Crüe", "motley crue"},
+		{"MÖTLEY CRÜE", "motley crue"},
+		{"São Paulo", "sao paulo"},
+		{"sao_paulo-brazil", "sao paulo brazil"},
+		{"  Roger  Meddows-Taylor, ", "roger meddows taylor"},
+		{"Guns N' Roses", "guns n roses"},
+		{"Simon & Garfunkel", "simon and garfunkel"},
+		{"AC&DC", "ac and dc"},
+		{"Straße", "strasse"},
+		{"Sigur Rós", "sigur ros"},
+		{"Łódź", "lodz"},
+		{"Björk Guðmundsdóttir", "bjork gudmundsdottir"},
+		{"*23-08-2019", "23 08 2019"},
+		{"Мумий Тролль", "мумий тролль"},
+		{"-_-", ""},
+	}
+	for _, tt := range tests {
+		got := Fold(tt.in)
+		if got != tt.want {
+			t.Errorf("Fold(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if again := Fold(got); again != got {
+			t.Errorf("Fold(%q) = %q, not idempotent", got, again)
+		}
+	}
+}
+
+func TestSearchIgnoresAccentsAndPunctuation(t *testing.T) {
+	ix := build([]model.Data{
+		{Id: 1, Name: "Beyoncé", DateAndLocation: model.DatesLocations{"sao_paulo-brazil": {"01-01-2020"}}},
+		{Id: 2, Name: "Mötley Crüe", Members: []string{"Nikki Sixx"}},
+		{Id: 3, Name: "Simon & Garfunkel"},
+	}, nil)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Beyonce", "1:name:Beyoncé"},
+		{"beyoncé", "1:name:Beyoncé"},
+		{"Motley", "2:name:Mötley Crüe"},
+		{"mötley crue", "2:name:Mötley Crüe"},
+		{"São Paulo", "1:location:sao_paulo-brazil"},
+		{"sao_paulo-brazil", "1:location:sao_paulo-brazil"},
+		{"simon and garfunkel", "3:name:Simon & Garfunkel"},
+		{"simon & garfunkel", "3:name:Simon & Garfunkel"},
+	}
+	for _, tt := range tests {
+		hits := ix.Search(tt.query)
+		if len(hits) == 0 {
+			t.Errorf("Search(%q) found nothing", tt.query)
+			continue
+		}
+		if got := values([]Entry{hits[0].Entry})[0]; got != tt.want || hits[0].Kind == MatchFuzzy {
+			t.Errorf("Search(%q) = %s (kind %d), want a non-fuzzy %s", tt.query, got, hits[0].Kind, tt.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// Metaphone returns the Metaphone key of a word: a rough spelling of how it
+// sounds in English, so that "Smyth" and "Smith" or "Filip" and "Philip"
+// share a key. It follows Lawrence Philips' original rules. Letters are
+// folded first and anything outside a-z is ignored; words without letters
+// have an empty key.
+func Metaphone(word string) string {
+	var letters []byte
+	for _, r := range Fold(word) {
+		if r >= 'a' && r <= 'z' {
+			letters = append(letters, byte(r)-'a'+'A')
+		}
+	}
+	if len(letters) == 0 {
+		return ""
+	}
+
+	// initial letters that are not pronounced, or not as written
+	switch w := string(letters); {
+	case strings.HasPrefix(w, "AE"), strings.HasPrefix(w, "GN"), strings.HasPrefix(w, "KN"),
+		strings.HasPrefix(w, "PN"), strings.HasPrefix(w, "WR"):
+		letters = letters[1:]
+	case w[0] == 'X':
+		letters[0] = 'S'
+	case strings.HasPrefix(w, "WH"):
+		letters = append([]byte{'W'}, letters[2:]...)
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(letters) {
+			return 0
+		}
+		return letters[i]
+	}
+	vowel := func(c byte) bool { return strings.IndexByte("AEIOU", c) >= 0 }
+	frontVowel := func(c byte) bool { return c == 'E' || c == 'I' || c == 'Y' }
+
+	var key strings.Builder
+	for i, c := range letters {
+		if c == at(i-1) && c != 'C' {
+			continue // doubled letters sound once
+		}
+		next, last := at(i+1), i == len(letters)-1
+
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				key.WriteByte(c)
+			}
+		case 'B':
+			if !(last && at(i-1) == 'M') {
+				key.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case next == 'I' && at(i+2) == 'A':
+				key.WriteByte('X')
+			case next == 'H':
+				if at(i-1) == 'S' {
+					key.WriteByte('K')
+				} else {
+					key.WriteByte('X')
+				}
+			case frontVowel(next):
+				if at(i-1) != 'S' {
+					key.WriteByte('S')
+				}
+			default:
+				key.WriteByte('K')
+			}
+		case 'D':
+			if next == 'G' && frontVowel(at(i+2)) {
+				key.WriteByte('J')
+			} else {
+				key.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case next == 'H' && !vowel(at(i+2)) && i+2 < len(letters):
+				// silent, as in "night"
+			case next == 'N' && (i+2 == len(letters) || string(letters[i+1:]) == "NED"):
+				// silent, as in "sign" and "signed"
+			case at(i-1) == 'D' && frontVowel(next):
+				// silent, as in "judge", where the D already sounds J
+			case frontVowel(next) && at(i-1) != 'G':
+				key.WriteByte('J')
+			default:
+				key.WriteByte('K')
+			}
+		case 'H':
+			if vowel(next) && strings.IndexByte("CSPTG", at(i-1)) < 0 {
+				key.WriteByte('H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				key.WriteByte('K')
+			}
+		case 'P':
+			if next == 'H' {
+				key.WriteByte('F')
+			} else {
+				key.WriteByte('P')
+			}
+		case 'Q':
+			key.WriteByte('K')
+		case 'S':
+			switch {
+			case next == 'H', next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				key.WriteByte('X')
+			default:
+				key.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				key.WriteByte('X')
+			case next == 'H':
+				key.WriteByte('0') // "th"
+			case next == 'C' && at(i+2) == 'H':
+				// silent, as in "watch"
+			default:
+				key.WriteByte('T')
+			}
+		case 'V':
+			key.WriteByte('F')
+		case 'W', 'Y':
+			if vowel(next) {
+				key.WriteByte(c)
+			}
+		case 'X':
+			key.WriteString("KS")
+		case 'Z':
+			key.WriteByte('S')
+		default: // F, J, L, M, N, R
+			key.WriteByte(c)
+		}
+	}
+	return key.String()
+}
+
+// phoneticMinLen is the shortest query token matched by sound: shorter ones
+// sound like too many words.
+const phoneticMinLen = 3
+
+// phoneticTokens returns the Metaphone keys of the tokens of a folded
+// query, or nil if some token is too short or has no key.
+func phoneticTokens(folded string) []string {
+	var keys []string
+	for _, token := range unique(strings.Fields(folded)) {
+		key := Metaphone(token)
+		if len([]rune(token)) < phoneticMinLen || key == "" || strings.ContainsAny(token, "0123456789") {
+			return nil
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// blendMinKey is the shortest key of a token Search matches by sound. Short
+// keys are shared by too many unrelated words to be blended into results
+// the user did not ask to be phonetic.
+const blendMinKey = 3
+
+// blendTokens returns the keys of phoneticTokens, or nil if one of them is
+// shorter than blendMinKey.
+func blendTokens(folded string) []string {
+	keys := phoneticTokens(folded)
+	for _, key := range keys {
+		if len(key) < blendMinKey {
+			return nil
+		}
+	}
+	return keys
+}
+
+// phonetic returns, in ascending order, the name and member entries that
+// have a word sounding like each of the query tokens keys were taken from.
+func (ix *Index) phonetic(keys []string) []int {
+	if len(keys) == 0 {
+		return nil
+	}
+	matched := make(map[int]int)
+	for i, key := range keys {
+		for _, pos := range ix.sounds[key] {
+			if matched[pos] == i {
+				matched[pos] = i + 1
+			}
+		}
+	}
+	var out []int
+	for pos, n := range matched {
+		if n == len(keys) {
+			out = append(out, pos)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// Phonetic returns the name and member entries of the given fields that
+// sound like query, word for word, best first. Only names and members are
+// indexed by sound.
+func (ix *Index) Phonetic(query string, fields ...Field) []Hit {
+	folded := Fold(query)
+	var hits []Hit
+	for _, pos := range ix.phonetic(phoneticTokens(folded)) {
+		if entry := ix.entries[pos]; hasField(fields, entry.Field) {
+			hits = append(hits, newHit(entry, pos, folded, MatchPhonetic, 0))
+		}
+	}
+	sortHits(hits)
+	return hits
+}
@@ -0,0 +1,114 @@
+package search
+
+import (
+	"testing"
+
+	model "tracker/models"
+)
+
+func TestMetaphone(t *testing.T) {
+	tests := []struct{ word, want string }{
+		{"Philip", "FLP"},
+		{"Filip", "FLP"},
+		{"Smith", "SM0"},
+		{"Smyth", "SM0"},
+		{"Mercury", "MRKR"},
+		{"Merkurie", "MRKR"},
+		{"Knight", "NT"},
+		{"Night", "NT"},
+		{"Xavier", "SFR"},
+		{"Wright", "RT"},
+		{"Thomas", "0MS"},
+		{"Church", "XRX"},
+		{"Science", "SNS"},
+		{"Judge", "JJ"},
+		{"Lamb", "LM"},
+		{"Sign", "SN"},
+		{"Nation", "NXN"},
+		{"Jäger", "JJR"},
+		{"Aerosmith", "ERSM0"},
+		{"1973", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Metaphone(tt.word); got != tt.want {
+			t.Errorf("Metaphone(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestPhonetic(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	tests := []struct {
+		query  string
+		fields []Field
+		want   []string
+	}{
+		{"frediy merkurie", nil, []string{"1:member:Freddie Mercury"}},
+		{"merkurie", []Field{FieldMember}, []string{"1:member:Freddie Mercury"}},
+		{"merkurie", []Field{FieldName}, nil},
+		{"kwin", nil, nil}, // Q sounds like K, but KN is not KWN
+		{"pinc floid", nil, []string{"2:name:Pink Floyd"}},
+		{"london", nil, nil}, // only names and members are matched by sound
+		{"de", nil, nil},     // too short to match by sound
+	}
+	for _, tt := range tests {
+		var got []Entry
+		for _, hit := range ix.Phonetic(tt.query, tt.fields...) {
+			if hit.Kind != MatchPhonetic {
+				t.Errorf("Phonetic(%q) hit of kind %d", tt.query, hit.Kind)
+			}
+			got = append(got, hit.Entry)
+		}
+		if g, w := values(got), tt.want; len(g) != len(w) || (len(g) > 0 && g[0] != w[0]) {
+			t.Errorf("Phonetic(%q) = %v, want %v", tt.query, g, w)
+		}
+	}
+}
+
+func TestSearchBlendsInPhoneticHits(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	// too far for typos, but it sounds right
+	hits := ix.Search("frediy merkurie")
+	if len(hits) != 1 || hits[0].Kind != MatchPhonetic || hits[0].Value != "Freddie Mercury" {
+		t.Fatalf("Search(frediy merkurie) = %+v", hits)
+	}
+
+	// a typo match is not repeated as a phonetic one, and outranks it
+	hits = ix.Search("mercuri")
+	if len(hits) != 1 || hits[0].Kind != MatchFuzzy {
+		t.Errorf("Search(mercuri) = %+v", hits)
+	}
+	if fuzzy, phonetic := hits[0].Score, ix.Phonetic("mercuri")[0].Score; fuzzy <= phonetic {
+		t.Errorf("fuzzy score %v not above phonetic score %v", fuzzy, phonetic)
+	}
+}
+
+func TestSearchSkipsNoisyPhoneticHits(t *testing.T) {
+	artists := append([]model.Data{{Id: 3, Name: "SOJA", Members: []string{"Ken Brownell"}}}, testArtists...)
+	ix := build(artists, testLocations)
+
+	// "queen" and "ken" both sound KN: too short a key to guess with
+	for _, hit := range ix.Search("queen") {
+		if hit.Kind == MatchPhonetic {
+			t.Errorf("Search(queen) phonetic hit %+v", hit)
+		}
+	}
+	for _, hit := range ix.Search("queen", FieldMember) {
+		t.Errorf("Search(queen, member) = %+v", hit)
+	}
+
+	// nor are sound-alikes looked for once an entry contains the query
+	for _, hit := range ix.Search("pink") {
+		if hit.Kind == MatchPhonetic {
+			t.Errorf("Search(pink) phonetic hit %+v", hit)
+		}
+	}
+
+	// phonetic mode still matches short keys
+	if hits := ix.Phonetic("queen", FieldMember); len(hits) != 1 || hits[0].Value != "Ken Brownell" {
+		t.Errorf("Phonetic(queen, member) = %+v", hits)
+	}
+}
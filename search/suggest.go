@@ -0,0 +1,140 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// suggestEdits is how far a word of the vocabulary may be from a query
+// token to be suggested for it. It is one more than maxEdits allows when
+// searching, since a suggestion is only offered once the search came up
+// short.
+func suggestEdits(token string) int {
+	n := 0
+	for _, r := range token {
+		if unicode.IsDigit(r) {
+			return 0
+		}
+		n++
+	}
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 4:
+		return 1
+	case n <= 7:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// alternative is a word of the vocabulary a query token may have meant.
+type alternative struct {
+	word     string
+	distance int
+	uses     int // how many entries hold the word
+}
+
+// Suggest returns up to n rewrites of query built from words of the index,
+// closest first, for a query that found little or nothing ("did you mean").
+// Tokens found inside some indexed word are kept; every other token is
+// replaced by the closest words, more common words first among equally
+// close ones. Rewrites that would find nothing either are left out.
+func (ix *Index) Suggest(query string, n int) []string {
+	tokens := strings.Fields(Fold(query))
+	if len(tokens) == 0 || n <= 0 {
+		return nil
+	}
+
+	options := make([][]alternative, len(tokens))
+	changed := false
+	for i, token := range tokens {
+		options[i] = ix.alternatives(token, n)
+		if len(options[i]) == 0 {
+			return nil // nothing close to this token at all
+		}
+		changed = changed || options[i][0].word != token
+	}
+	if !changed {
+		return nil
+	}
+
+	// the best rewrite takes the first option of every token; the next ones
+	// swap in a single other option, cheapest first
+	type rewrite struct {
+		words    []string
+		distance int
+	}
+	best := rewrite{words: make([]string, len(tokens))}
+	for i, opts := range options {
+		best.words[i] = opts[0].word
+		best.distance += opts[0].distance
+	}
+	rewrites := []rewrite{best}
+	for i, opts := range options {
+		for _, alt := range opts[1:] {
+			words := append([]string(nil), best.words...)
+			words[i] = alt.word
+			rewrites = append(rewrites, rewrite{words, best.distance - opts[0].distance + alt.distance})
+		}
+	}
+	sort.SliceStable(rewrites, func(i, j int) bool { return rewrites[i].distance < rewrites[j].distance })
+
+	folded := strings.Join(tokens, " ")
+	var out []string
+	for _, r := range rewrites {
+		suggestion := strings.Join(r.words, " ")
+		if suggestion == folded || len(ix.lookup(suggestion, nil)) == 0 {
+			continue
+		}
+		out = append(out, suggestion)
+		if len(out) == n {
+			break
+		}
+	}
+	return out
+}
+
+// alternatives returns up to n words of the vocabulary token may stand for:
+// just token itself if some word contains it, otherwise the closest words
+// within suggestEdits.
+func (ix *Index) alternatives(token string, n int) []alternative {
+	for _, word := range ix.vocab {
+		if strings.Contains(word, token) {
+			return []alternative{{word: token}}
+		}
+	}
+
+	limit := suggestEdits(token)
+	if limit == 0 {
+		return nil
+	}
+	tokenRunes := []rune(token)
+	buf := make([]int, 3*(len(tokenRunes)+limit+1))
+	var alts []alternative
+	for w, word := range ix.vocab {
+		if abs(len(ix.runes[w])-len(tokenRunes)) > limit {
+			continue
+		}
+		if d, _ := distances(tokenRunes, ix.runes[w], limit, buf); d <= limit {
+			alts = append(alts, alternative{word: word, distance: d, uses: len(ix.postings[word])})
+		}
+	}
+
+	sort.Slice(alts, func(i, j int) bool {
+		a, b := alts[i], alts[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.uses != b.uses {
+			return a.uses > b.uses
+		}
+		return a.word < b.word
+	})
+	if len(alts) > n {
+		alts = alts[:n]
+	}
+	return alts
+}
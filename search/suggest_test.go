@@ -0,0 +1,44 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Quen", []string{"queen"}},
+		{"pinc floid", []string{"pink floyd"}},
+		{"Frediee Mercury", []string{"freddie mercury"}},
+		{"londen", []string{"london"}},
+		{"mercury", nil}, // already matches
+		{"zzzzzz", nil},  // nothing close
+		{"queen zzzzzz", nil},
+		{"2091", nil}, // numbers are not corrected
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := ix.Suggest(tt.query, 3)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestPrefersCloserWords(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	// "usa" is one edit from "uda", "uk" two
+	got := ix.Suggest("uda", 3)
+	if len(got) == 0 || got[0] != "usa" {
+		t.Errorf("Suggest(uda) = %q, want usa first", got)
+	}
+	if got := ix.Suggest("uda", 1); len(got) != 1 {
+		t.Errorf("Suggest(uda, 1) = %q, want a single suggestion", got)
+	}
+}
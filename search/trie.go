@@ -0,0 +1,129 @@
+package search
+
+import "sort"
+
+// Completion is a name, member or location offered for a prefix typed in
+// the search box.
+type Completion struct {
+	Field Field
+	// ArtistID is the artist of a name or member. Locations are shared by
+	// every artist that played there and have none.
+	ArtistID int
+	Value    string
+	// Popularity is the number of concerts behind the completion: of the
+	// artist for names and members, at the place for locations.
+	Popularity int
+}
+
+// MaxCompletions is the most completions Complete returns: each trie node
+// keeps that many.
+const MaxCompletions = 16
+
+// trie maps every word-start suffix of the folded completions to the most
+// popular of them, so a prefix of any word finds a value: "merc" finds
+// "Freddie Mercury".
+type trie struct {
+	root        *trieNode
+	completions []Completion
+	keys        []string // folded completion values
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	top      []int // completions below this node, most popular first, up to MaxCompletions
+}
+
+func newTrie() *trie {
+	return &trie{root: &trieNode{}}
+}
+
+// add indexes c under each word of value.
+func (t *trie) add(c Completion) {
+	id := len(t.completions)
+	key := Fold(c.Value)
+	t.completions = append(t.completions, c)
+	t.keys = append(t.keys, key)
+
+	folded := []rune(key)
+	for start := range folded {
+		if start > 0 && folded[start-1] != ' ' {
+			continue
+		}
+		node := t.root
+		for _, r := range folded[start:] {
+			child := node.children[r]
+			if child == nil {
+				child = &trieNode{}
+				if node.children == nil {
+					node.children = make(map[rune]*trieNode)
+				}
+				node.children[r] = child
+			}
+			node = child
+			t.offer(node, id)
+		}
+	}
+}
+
+// offer ranks completion id into the top list of node.
+func (t *trie) offer(node *trieNode, id int) {
+	for _, existing := range node.top {
+		if existing == id {
+			return // reached again through a later word
+		}
+	}
+	i := sort.Search(len(node.top), func(i int) bool {
+		return t.less(id, node.top[i])
+	})
+	if i >= MaxCompletions {
+		return
+	}
+	node.top = append(node.top, 0)
+	copy(node.top[i+1:], node.top[i:])
+	node.top[i] = id
+	if len(node.top) > MaxCompletions {
+		node.top = node.top[:MaxCompletions]
+	}
+}
+
+// completionFields ranks equally popular completions: an artist before its
+// members, and both before places.
+var completionFields = map[Field]int{FieldName: 0, FieldMember: 1, FieldLocation: 2}
+
+// less orders completions by popularity, then field, then alphabetically.
+func (t *trie) less(a, b int) bool {
+	ca, cb := t.completions[a], t.completions[b]
+	if ca.Popularity != cb.Popularity {
+		return ca.Popularity > cb.Popularity
+	}
+	if fa, fb := completionFields[ca.Field], completionFields[cb.Field]; fa != fb {
+		return fa < fb
+	}
+	if t.keys[a] != t.keys[b] {
+		return t.keys[a] < t.keys[b]
+	}
+	return a < b
+}
+
+// Complete returns up to n names, members and locations with a word
+// starting with prefix once both are folded, most popular first.
+func (ix *Index) Complete(prefix string, n int) []Completion {
+	folded := Fold(prefix)
+	if folded == "" || n <= 0 {
+		return nil
+	}
+	node := ix.trie.root
+	for _, r := range folded {
+		if node = node.children[r]; node == nil {
+			return nil
+		}
+	}
+	out := make([]Completion, 0, min(n, len(node.top)))
+	for _, id := range node.top {
+		if len(out) == n {
+			break
+		}
+		out = append(out, ix.trie.completions[id])
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func completions(cs []Completion) string {
+	var out []string
+	for _, c := range cs {
+		out = append(out, fmt.Sprintf("%s:%d:%s:%d", c.Field, c.ArtistID, c.Value, c.Popularity))
+	}
+	return strings.Join(out, ",")
+}
+
+func TestComplete(t *testing.T) {
+	ix := build(testArtists, testLocations)
+
+	tests := []struct {
+		prefix string
+		n      int
+		want   string
+	}{
+		{"que", 5, "name:1:Queen:2"},
+		{"MERC", 5, "member:1:Freddie Mercury:2"},
+		{"m", 5, "member:1:Brian May:2,member:1:Freddie Mercury:2,location:0:manchester-uk:0"},
+		{"m", 2, "member:1:Brian May:2,member:1:Freddie Mercury:2"},
+		{"carolina", 5, "location:0:north_carolina-usa:1"},
+		{"north carolina u", 5, "location:0:north_carolina-usa:1"},
+		{"uk", 5, "location:0:london-uk:1,location:0:manchester-uk:0"},
+		{"ueen", 5, ""}, // only the start of words
+		{"1970", 5, ""}, // dates are not completed
+		{"", 5, ""},
+	}
+	for _, tt := range tests {
+		if got := completions(ix.Complete(tt.prefix, tt.n)); got != tt.want {
+			t.Errorf("Complete(%q, %d) = %s, want %s", tt.prefix, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteKeepsTheMostPopular(t *testing.T) {
+	tr := newTrie()
+	for i := 0; i < 3*MaxCompletions; i++ {
+		c := Completion{Field: FieldName, ArtistID: i, Value: fmt.Sprintf("band %02d", i), Popularity: i % 7}
+		tr.add(c)
+	}
+	ix := &Index{trie: tr}
+
+	got := ix.Complete("band", 100)
+	if len(got) != MaxCompletions {
+		t.Fatalf("Complete returned %d completions, want %d", len(got), MaxCompletions)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Popularity > got[i-1].Popularity {
+			t.Errorf("completion %d (%d) more popular than %d (%d)", i, got[i].Popularity, i-1, got[i-1].Popularity)
+		}
+	}
+	if got[len(got)-1].Popularity < 4 {
+		t.Errorf("least popular kept = %+v, want popularity of at least 4", got[len(got)-1])
+	}
+}
@@ -0,0 +1,84 @@
+package src
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"tracker/search"
+)
+
+// AliasFile is an alias dictionary kept in a file, in the format of
+// search.ParseAliases. It is read again whenever the file changes, so
+// aliases can be edited while the server runs. It is safe for concurrent
+// use.
+type AliasFile struct {
+	path string
+
+	mu         sync.Mutex
+	checked    bool // modTime and size are those of the last read
+	missing    bool // the file could not be found last time
+	modTime    time.Time
+	size       int64
+	aliases    *search.Aliases
+	generation uint64
+}
+
+// NewAliasFile returns the alias dictionary kept at path. The file is only
+// read when the aliases are first asked for.
+func NewAliasFile(path string) *AliasFile {
+	return &AliasFile{path: path}
+}
+
+// Current returns the aliases as the file currently holds them, with their
+// generation, which grows every time the file is read again so anything
+// derived from the aliases can tell they changed. When the file is missing
+// or cannot be parsed the previous aliases are kept, so a half-saved edit
+// never takes them away. A nil AliasFile has no aliases.
+func (f *AliasFile) Current() (*search.Aliases, uint64) {
+	if f == nil {
+		return nil, 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if !f.missing {
+			log.Println("Alias file:", err)
+			f.missing = true
+		}
+		return f.aliases, f.generation
+	}
+	f.missing = false
+	if f.checked && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.aliases, f.generation
+	}
+
+	// remember the attempt even if it fails, so a bad file is reported once
+	// per edit
+	f.checked, f.modTime, f.size = true, info.ModTime(), info.Size()
+	aliases, err := f.load()
+	if err != nil {
+		log.Println("Alias file:", err)
+		return f.aliases, f.generation
+	}
+	f.aliases = aliases
+	f.generation++
+	return f.aliases, f.generation
+}
+
+func (f *AliasFile) load() (*search.Aliases, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	aliases, err := search.ParseAliases(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.path, err)
+	}
+	return aliases, nil
+}
@@ -0,0 +1,54 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAliasFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.txt")
+	f := NewAliasFile(path)
+
+	// missing file: no aliases yet
+	if aliases, generation := f.Current(); aliases.Len() != 0 || generation != 0 {
+		t.Errorf("Current() of a missing file = %d aliases, generation %d", aliases.Len(), generation)
+	}
+
+	write := func(content string, age time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// make every version look modified, however coarse the file clock
+		stamp := time.Now().Add(-age)
+		os.Chtimes(path, stamp, stamp)
+	}
+
+	write("rhcp = red hot chili peppers\n", 3*time.Hour)
+	aliases, generation := f.Current()
+	if got := aliases.Expand("rhcp"); len(got) != 1 || generation != 1 {
+		t.Fatalf("Current() = %q, generation %d", got, generation)
+	}
+	if again, g := f.Current(); again != aliases || g != generation {
+		t.Errorf("an unchanged file was read again")
+	}
+
+	// a broken edit keeps the previous aliases
+	write("rhcp red hot chili peppers\n", 2*time.Hour)
+	if again, g := f.Current(); again != aliases || g != generation {
+		t.Errorf("a broken file replaced the aliases")
+	}
+
+	write("rhcp = red hot chili peppers\nnyc = new york\n", time.Hour)
+	aliases, generation = f.Current()
+	if aliases.Len() != 2 || generation != 2 {
+		t.Errorf("after an edit: %d aliases, generation %d, want 2 and 2", aliases.Len(), generation)
+	}
+
+	var none *AliasFile
+	if aliases, _ := none.Current(); aliases != nil {
+		t.Errorf("nil AliasFile has aliases")
+	}
+}
@@ -0,0 +1,278 @@
+package src
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	model "tracker/models"
+	"tracker/search"
+)
+
+// Snapshot is an immutable view of every collection at one point in time.
+// A refresh builds a new Snapshot and swaps it in atomically, so a reader
+// holding one never sees a partially updated catalog. Nothing returned by a
+// Snapshot may be modified.
+type Snapshot struct {
+	// Version increases by one with every successful load; zero means the
+	// catalog has never been loaded.
+	Version  uint64
+	LoadedAt time.Time
+
+	artists   []model.Data
+	byID      map[int]int
+	locations []model.Location
+	dates     map[int]model.Date
+	places    map[string]model.Place
+	concerts  map[int][]model.Concert
+	report    ConcertReport
+	index     *search.Index
+}
+
+var emptySnapshot = &Snapshot{index: search.Build(nil, nil, nil)}
+
+// Artists returns every artist with its relations filled in.
+func (s *Snapshot) Artists() []model.Data {
+	return s.artists
+}
+
+// Artist returns the artist with the given id.
+func (s *Snapshot) Artist(id int) (model.Data, bool) {
+	i, ok := s.byID[id]
+	if !ok {
+		return model.Data{}, false
+	}
+	return s.artists[i], true
+}
+
+// Locations returns the locations of every artist.
+func (s *Snapshot) Locations() []model.Location {
+	return s.locations
+}
+
+// Location returns the locations of the artist with the given id.
+func (s *Snapshot) Location(id int) (model.Location, bool) {
+	for _, location := range s.locations {
+		if location.ArtistId == id {
+			return location, true
+		}
+	}
+	return model.Location{}, false
+}
+
+// Dates returns the concert dates of the artist with the given id, without
+// the leading '*' the API puts on some of them.
+func (s *Snapshot) Dates(id int) (model.Date, bool) {
+	dates, ok := s.dates[id]
+	return dates, ok
+}
+
+// Place returns the parsed location key, with its coordinates when the
+// gazetteer of the catalog knows them.
+func (s *Snapshot) Place(key string) model.Place {
+	if p, ok := s.places[key]; ok {
+		return p
+	}
+	return model.ParseLocation(key)
+}
+
+// Places returns every location of the catalog, sorted by key.
+func (s *Snapshot) Places() []model.Place {
+	places := make([]model.Place, 0, len(s.places))
+	for _, p := range s.places {
+		places = append(places, p)
+	}
+	sort.Slice(places, func(i, j int) bool { return places[i].Key < places[j].Key })
+	return places
+}
+
+// ArtistConcerts returns every concert of the artist with the given id, in
+// chronological order. Concerts whose date cannot be read come last.
+func (s *Snapshot) ArtistConcerts(id int) []model.Concert {
+	return s.concerts[id]
+}
+
+// ConcertReport returns the malformed concert entries found while loading
+// this snapshot.
+func (s *Snapshot) ConcertReport() ConcertReport {
+	return s.report
+}
+
+// Index returns the search index built over this snapshot.
+func (s *Snapshot) Index() *search.Index {
+	return s.index
+}
+
+// Catalog keeps the current Snapshot and refreshes it from a DataSource.
+// Readers never wait on the network or on a lock: a refresh downloads
+// everything first and then swaps the new Snapshot in.
+type Catalog struct {
+	source    DataSource
+	ttl       time.Duration
+	gazetteer *model.Gazetteer
+
+	loadMu  sync.Mutex // serialises loads so concurrent refreshes share one download
+	current atomic.Pointer[Snapshot]
+}
+
+// NewCatalog returns an empty catalog reading from source. A ttl of zero
+// disables background refreshes.
+func NewCatalog(source DataSource, ttl time.Duration) *Catalog {
+	return &Catalog{source: source, ttl: ttl}
+}
+
+// SetGazetteer makes the following loads locate places with g.
+func (c *Catalog) SetGazetteer(g *model.Gazetteer) {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	c.gazetteer = g
+}
+
+// Snapshot returns the current snapshot. It is never nil; before the first
+// successful load it is empty with Version zero.
+func (c *Catalog) Snapshot() *Snapshot {
+	if s := c.current.Load(); s != nil {
+		return s
+	}
+	return emptySnapshot
+}
+
+// Load downloads every collection and swaps in a new snapshot. On error the
+// previous snapshot is kept.
+func (c *Catalog) Load() error {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	return c.load()
+}
+
+// EnsureLoaded loads the catalog unless a previous load already succeeded.
+// Once one has, it returns at once, without waiting on a refresh.
+func (c *Catalog) EnsureLoaded() error {
+	if c.Snapshot().Version != 0 {
+		return nil
+	}
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if c.Snapshot().Version != 0 {
+		return nil
+	}
+	return c.load()
+}
+
+func (c *Catalog) load() error {
+	artists, err := c.source.Artists()
+	if err != nil {
+		return err
+	}
+	locations, err := c.source.Locations()
+	if err != nil {
+		return err
+	}
+	dates, err := c.source.Dates()
+	if err != nil {
+		return err
+	}
+	relations, err := c.source.Relations()
+	if err != nil {
+		return err
+	}
+
+	snap := buildSnapshot(c.Snapshot().Version+1, artists, locations, dates, relations, c.gazetteer)
+	if issues := len(snap.report.Issues); issues > 0 {
+		log.Printf("Catalog: %d malformed concert entries", issues)
+	}
+	c.current.Store(snap)
+	return nil
+}
+
+// buildSnapshot copies everything it keeps, so the snapshot shares no memory
+// with the data source. Places are located with gazetteer, which may be nil.
+func buildSnapshot(version uint64, artists []model.Artist, locations []model.Location, dates []model.Date, relations []model.DatesLocation, gazetteer *model.Gazetteer) *Snapshot {
+	places := make(map[int]model.DatesLocations, len(relations))
+	for _, relation := range relations {
+		copied := make(model.DatesLocations, len(relation.Places))
+		for location, days := range relation.Places {
+			copied[location] = append([]string(nil), days...)
+		}
+		places[relation.Id] = copied
+	}
+
+	s := &Snapshot{
+		Version:   version,
+		LoadedAt:  time.Now(),
+		artists:   make([]model.Data, 0, len(artists)),
+		byID:      make(map[int]int, len(artists)),
+		locations: make([]model.Location, 0, len(locations)),
+		places:    make(map[string]model.Place),
+		dates:     make(map[int]model.Date, len(dates)),
+	}
+
+	for _, artist := range artists {
+		s.byID[artist.Id] = len(s.artists)
+		s.artists = append(s.artists, model.Data{
+			Name:            artist.Name,
+			Id:              artist.Id,
+			Image:           artist.Image,
+			Members:         append([]string(nil), artist.Members...),
+			CreationDate:    artist.CreationDate,
+			FirstAlbum:      artist.FirstAlbum,
+			DateAndLocation: places[artist.Id],
+		})
+	}
+
+	for _, location := range locations {
+		location.Locations = append([]string(nil), location.Locations...)
+		s.locations = append(s.locations, location)
+	}
+
+	for _, date := range dates {
+		trimmed := model.Date{Id: date.Id, Dates: make([]string, len(date.Dates))}
+		for i, d := range date.Dates {
+			trimmed.Dates[i] = model.CleanDate(d)
+		}
+		s.dates[date.Id] = trimmed
+	}
+
+	addPlace := func(key string) {
+		if _, ok := s.places[key]; !ok {
+			s.places[key] = gazetteer.Geocode(model.ParseLocation(key))
+		}
+	}
+	for _, artist := range s.artists {
+		for key := range artist.DateAndLocation {
+			addPlace(key)
+		}
+	}
+	for _, location := range s.locations {
+		for _, key := range location.Locations {
+			addPlace(key)
+		}
+	}
+
+	s.concerts, s.report = buildConcerts(s.artists, s.byID, dates, relations, s.places)
+	s.index = search.Build(s.artists, s.locations, s.concerts)
+	return s
+}
+
+// Run refreshes the catalog every ttl until stop is closed. Failed refreshes
+// are logged and the current snapshot keeps being served.
+func (c *Catalog) Run(stop <-chan struct{}) {
+	if c.ttl <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := c.Load(); err != nil {
+				log.Println("Catalog refresh error:", err)
+			}
+		}
+	}
+}
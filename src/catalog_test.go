@@ -0,0 +1,196 @@
+package src
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	model "tracker/models"
+)
+
+// countingSource counts how often the artists collection is read and can be
+// told to fail, or to hang until hold is closed.
+type countingSource struct {
+	DataSource
+	calls atomic.Int32
+	fail  atomic.Bool
+	hold  chan struct{}
+}
+
+func (s *countingSource) Artists() ([]model.Artist, error) {
+	s.calls.Add(1)
+	if s.hold != nil {
+		<-s.hold
+	}
+	if s.fail.Load() {
+		return nil, errors.New("upstream down")
+	}
+	return s.DataSource.Artists()
+}
+
+func TestCatalogLoad(t *testing.T) {
+	c := NewCatalog(NewDirSource(testDataDir), 0)
+	if len(c.Snapshot().Artists()) != 0 || c.Snapshot().Version != 0 {
+		t.Fatalf("new catalog is not empty")
+	}
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	snap := c.Snapshot()
+	if snap.Version != 1 {
+		t.Errorf("Snapshot().Version = %d after first load, want 1", snap.Version)
+	}
+
+	artist, ok := snap.Artist(1)
+	if !ok || artist.Name != "Queen" {
+		t.Fatalf("Artist(1) = %q, %v, want Queen", artist.Name, ok)
+	}
+	if got := artist.DateAndLocation["osaka-japan"]; len(got) != 1 || got[0] != "28-01-2020" {
+		t.Errorf("Artist(1).DateAndLocation[osaka-japan] = %v", got)
+	}
+
+	dates, ok := snap.Dates(1)
+	if !ok || len(dates.Dates) == 0 {
+		t.Fatalf("Dates(1) = %v, %v", dates, ok)
+	}
+	for _, d := range dates.Dates {
+		if d[0] == '*' {
+			t.Errorf("Dates(1) kept the '*' prefix on %q", d)
+		}
+	}
+
+	location, ok := snap.Location(3)
+	if !ok || len(location.Locations) != 4 {
+		t.Errorf("Location(3) = %v, %v", location, ok)
+	}
+	if _, ok := snap.Artist(99); ok {
+		t.Errorf("Artist(99) found an artist")
+	}
+}
+
+func TestSnapshotIsolatedFromSource(t *testing.T) {
+	artists := []model.Artist{{Id: 1, Name: "Queen", Members: []string{"Freddie Mercury"}}}
+	relations := []model.DatesLocation{{Id: 1, Places: model.DatesLocations{"london-uk": {"01-01-2020"}}}}
+	c := NewCatalog(NewMemorySource(artists, nil, nil, relations), 0)
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	artists[0].Members[0] = "changed"
+	relations[0].Places["london-uk"][0] = "changed"
+
+	artist, _ := c.Snapshot().Artist(1)
+	if artist.Members[0] != "Freddie Mercury" || artist.DateAndLocation["london-uk"][0] != "01-01-2020" {
+		t.Errorf("snapshot shares memory with its source: %+v", artist)
+	}
+}
+
+func TestCatalogKeepsDataOnFailedRefresh(t *testing.T) {
+	source := &countingSource{DataSource: NewDirSource(testDataDir)}
+	c := NewCatalog(source, 0)
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	before := c.Snapshot()
+
+	source.fail.Store(true)
+	if err := c.Load(); err == nil {
+		t.Fatalf("Load() error = nil, want error")
+	}
+	if c.Snapshot() != before {
+		t.Errorf("failed refresh replaced the snapshot")
+	}
+	if err := c.EnsureLoaded(); err != nil {
+		t.Errorf("EnsureLoaded() on a loaded catalog = %v", err)
+	}
+	if source.calls.Load() != 2 {
+		t.Errorf("EnsureLoaded() reloaded a loaded catalog")
+	}
+}
+
+func TestEnsureLoadedSkipsRefresh(t *testing.T) {
+	source := &countingSource{DataSource: NewDirSource(testDataDir)}
+	c := NewCatalog(source, 0)
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// a refresh stuck on the upstream holds the load lock
+	source.hold = make(chan struct{})
+	defer close(source.hold)
+	go c.Load()
+	for source.calls.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error)
+	go func() { done <- c.EnsureLoaded() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("EnsureLoaded() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("EnsureLoaded() waited on a refresh")
+	}
+}
+
+func TestCatalogRunRefreshes(t *testing.T) {
+	source := &countingSource{DataSource: NewDirSource(testDataDir)}
+	c := NewCatalog(source, 5*time.Millisecond)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		c.Run(stop)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for source.calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	if source.calls.Load() < 2 {
+		t.Errorf("Run() refreshed %d times, want at least 2", source.calls.Load())
+	}
+	if snap := c.Snapshot(); len(snap.Artists()) != 6 || snap.Version < 2 {
+		t.Errorf("Snapshot() = version %d with %d artists after refresh", snap.Version, len(snap.Artists()))
+	}
+}
+
+func TestSnapshotPlaces(t *testing.T) {
+	gazetteer, err := model.ParseGazetteer(strings.NewReader("london-uk, 51.5074, -0.1278\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	artists := []model.Artist{{Id: 1, Name: "Queen"}}
+	locations := []model.Location{{ArtistId: 1, Locations: []string{"london-uk", "osaka-japan"}}}
+	relations := []model.DatesLocation{{Id: 1, Places: model.DatesLocations{"london-uk": {"01-01-2020"}}}}
+	c := NewCatalog(NewMemorySource(artists, locations, nil, relations), 0)
+	c.SetGazetteer(gazetteer)
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	snap := c.Snapshot()
+
+	places := snap.Places()
+	if len(places) != 2 || places[0].Key != "london-uk" || places[1].Key != "osaka-japan" {
+		t.Fatalf("Places() = %+v, want london-uk and osaka-japan", places)
+	}
+	if places[0].Coords == nil || places[0].Coords.Lat != 51.5074 || places[1].Coords != nil {
+		t.Errorf("Places() coordinates = %v, %v", places[0].Coords, places[1].Coords)
+	}
+	if p := snap.Place("london-uk"); p.Coords == nil || p.Name != "London, UK" {
+		t.Errorf("Place(london-uk) = %+v", p)
+	}
+	if p := snap.Place("paris-france"); p.Name != "Paris, France" || p.Coords != nil {
+		t.Errorf("Place(paris-france) = %+v, want it parsed without coordinates", p)
+	}
+	if concerts := snap.ArtistConcerts(1); len(concerts) != 1 || concerts[0].Location.Coords == nil {
+		t.Errorf("ArtistConcerts(1) = %+v, want the concert located", concerts)
+	}
+}
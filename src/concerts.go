@@ -0,0 +1,120 @@
+package src
+
+import (
+	"sort"
+	"strings"
+
+	model "tracker/models"
+)
+
+// ConcertIssue is a malformed entry found while loading concerts.
+type ConcertIssue struct {
+	ArtistID int    `json:"artistId"`
+	Source   string `json:"source"` // "relation" or "dates", the endpoint listing the entry
+	Location string `json:"location,omitempty"`
+	Date     string `json:"date"` // As the API wrote it
+	Problem  string `json:"problem"`
+}
+
+// ConcertReport sums up the concerts of a snapshot and lists the entries
+// that could not be used as they are.
+type ConcertReport struct {
+	Concerts int            `json:"concerts"` // Every entry of the relation endpoint
+	Valid    int            `json:"valid"`    // Those with a date that could be read
+	Issues   []ConcertIssue `json:"issues"`
+}
+
+// concertProblems describe the flags that make an entry malformed
+var concertProblems = []struct {
+	flag    model.ConcertFlags
+	problem string
+}{
+	{model.InvalidDate, "invalid date"},
+	{model.UnknownCountry, "unknown country"},
+	{model.Duplicate, "duplicate date"},
+}
+
+// buildConcerts reads the concerts of every artist from its relations,
+// flags the dates the dates endpoint stars, and checks both endpoints
+// against each other. Concerts take their location from places when it
+// holds their key. Concerts of each artist are in chronological order.
+func buildConcerts(artists []model.Data, byID map[int]int, dates []model.Date, relations []model.DatesLocation, places map[string]model.Place) (map[int][]model.Concert, ConcertReport) {
+	report := ConcertReport{Issues: []ConcertIssue{}}
+	issue := func(i ConcertIssue) {
+		report.Issues = append(report.Issues, i)
+	}
+
+	for _, relation := range relations {
+		if _, ok := byID[relation.Id]; !ok {
+			issue(ConcertIssue{ArtistID: relation.Id, Source: "relation", Problem: "unknown artist"})
+		}
+	}
+
+	// clean date -> whether the dates endpoint stars it, by artist
+	listed := make(map[int]map[string]bool, len(dates))
+	for _, date := range dates {
+		if _, ok := byID[date.Id]; !ok {
+			issue(ConcertIssue{ArtistID: date.Id, Source: "dates", Problem: "unknown artist"})
+			continue
+		}
+		starred := make(map[string]bool, len(date.Dates))
+		for _, raw := range date.Dates {
+			if _, err := model.ParseDate(raw); err != nil {
+				issue(ConcertIssue{ArtistID: date.Id, Source: "dates", Date: raw, Problem: "invalid date"})
+			}
+			clean := model.CleanDate(raw)
+			starred[clean] = starred[clean] || strings.HasPrefix(strings.TrimSpace(raw), "*")
+		}
+		listed[date.Id] = starred
+	}
+
+	concerts := make(map[int][]model.Concert, len(artists))
+	for _, artist := range artists {
+		starred, hasDates := listed[artist.Id]
+		played := make(map[string]bool)
+		list := model.ParseConcerts(artist.Id, artist.DateAndLocation)
+		for i, c := range list {
+			clean := model.CleanDate(c.Raw)
+			played[clean] = true
+			if starred[clean] {
+				list[i].Flags |= model.Starred
+			}
+			if place, ok := places[c.Location.Key]; ok {
+				list[i].Location = place
+			}
+			if hasDates {
+				if _, ok := starred[clean]; !ok {
+					issue(ConcertIssue{ArtistID: c.ArtistID, Source: "relation", Location: c.Location.Key, Date: c.Raw, Problem: "missing from dates"})
+				}
+			}
+			for _, p := range concertProblems {
+				if c.Flags.Has(p.flag) {
+					issue(ConcertIssue{ArtistID: c.ArtistID, Source: "relation", Location: c.Location.Key, Date: c.Raw, Problem: p.problem})
+				}
+			}
+			report.Concerts++
+			if c.Valid() {
+				report.Valid++
+			}
+		}
+
+		var missing []string
+		for clean := range starred {
+			if !played[clean] {
+				missing = append(missing, clean)
+			}
+		}
+		sort.Strings(missing)
+		for _, clean := range missing {
+			issue(ConcertIssue{ArtistID: artist.Id, Source: "dates", Date: clean, Problem: "missing from relations"})
+		}
+
+		model.SortConcerts(list)
+		concerts[artist.Id] = list
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		return report.Issues[i].ArtistID < report.Issues[j].ArtistID
+	})
+	return concerts, report
+}
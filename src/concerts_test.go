@@ -0,0 +1,96 @@
+package src
+
+import (
+	"testing"
+	"time"
+
+	model "tracker/models"
+)
+
+func TestSnapshotConcerts(t *testing.T) {
+	c := NewCatalog(NewDirSource(testDataDir), 0)
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	snap := c.Snapshot()
+
+	report := snap.ConcertReport()
+	if len(report.Issues) != 0 {
+		t.Errorf("ConcertReport().Issues = %+v, want none for the test data", report.Issues)
+	}
+	// the index dates the concerts of the snapshot instead of parsing its own
+	all := snap.Index().ConcertsBetween(time.Time{}, time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC))
+	if report.Concerts == 0 || report.Valid != report.Concerts || len(all) != report.Valid {
+		t.Errorf("ConcertReport() = %+v with %d concerts in the index", report, len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Date.Before(all[i-1].Date) {
+			t.Fatalf("ConcertsBetween() not chronological: %v before %v", all[i-1].Date, all[i].Date)
+		}
+	}
+
+	// the dates endpoint stars the first date of each location of Queen
+	starred := 0
+	for _, concert := range snap.ArtistConcerts(1) {
+		if concert.ArtistID != 1 {
+			t.Errorf("ArtistConcerts(1) holds %+v", concert)
+		}
+		if concert.Flags.Has(model.Starred) {
+			starred++
+		}
+	}
+	if locations, _ := snap.Location(1); starred != len(locations.Locations) {
+		t.Errorf("ArtistConcerts(1) has %d starred concerts, want one per location (%d)", starred, len(locations.Locations))
+	}
+}
+
+func TestConcertReport(t *testing.T) {
+	artists := []model.Artist{{Id: 1, Name: "Queen"}}
+	dates := []model.Date{
+		{Id: 1, Dates: []string{"*01-01-2020", "02-01-2020", "03-01-2020"}},
+		{Id: 9, Dates: []string{"01-01-2020"}},
+	}
+	relations := []model.DatesLocation{
+		{Id: 1, Places: model.DatesLocations{
+			"london-uk":       {"01-01-2020", "01-01-2020"},
+			"oz-emerald_land": {"31-02-2020", "02-01-2020"},
+		}},
+		{Id: 9, Places: model.DatesLocations{"london-uk": {"01-01-2020"}}},
+	}
+	c := NewCatalog(NewMemorySource(artists, nil, dates, relations), 0)
+	if err := c.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	snap := c.Snapshot()
+
+	want := []ConcertIssue{
+		{ArtistID: 1, Source: "relation", Location: "london-uk", Date: "01-01-2020", Problem: "duplicate date"},
+		{ArtistID: 1, Source: "relation", Location: "oz-emerald_land", Date: "31-02-2020", Problem: "missing from dates"},
+		{ArtistID: 1, Source: "relation", Location: "oz-emerald_land", Date: "31-02-2020", Problem: "invalid date"},
+		{ArtistID: 1, Source: "relation", Location: "oz-emerald_land", Date: "31-02-2020", Problem: "unknown country"},
+		{ArtistID: 1, Source: "relation", Location: "oz-emerald_land", Date: "02-01-2020", Problem: "unknown country"},
+		{ArtistID: 1, Source: "dates", Date: "03-01-2020", Problem: "missing from relations"},
+		{ArtistID: 9, Source: "relation", Problem: "unknown artist"},
+		{ArtistID: 9, Source: "dates", Problem: "unknown artist"},
+	}
+	report := snap.ConcertReport()
+	if len(report.Issues) != len(want) {
+		t.Fatalf("ConcertReport().Issues = %+v, want %d issues", report.Issues, len(want))
+	}
+	for i := range want {
+		if report.Issues[i] != want[i] {
+			t.Errorf("issue %d = %+v, want %+v", i, report.Issues[i], want[i])
+		}
+	}
+	if report.Concerts != 4 || report.Valid != 3 {
+		t.Errorf("ConcertReport() counts %d concerts, %d valid, want 4 and 3", report.Concerts, report.Valid)
+	}
+
+	concerts := snap.ArtistConcerts(1)
+	if len(concerts) != 4 || concerts[3].Raw != "31-02-2020" {
+		t.Errorf("ArtistConcerts(1) = %+v, want the invalid date last", concerts)
+	}
+	if !concerts[0].Flags.Has(model.Starred) || concerts[2].Flags.Has(model.Starred) {
+		t.Errorf("ArtistConcerts(1) flags = %v, %v, want only 01-01-2020 starred", concerts[0].Flags, concerts[2].Flags)
+	}
+}
@@ -1,57 +1,26 @@
 package src
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strconv"
 
 	model "tracker/models"
 )
 
-var Data model.Data
-
 func FetchArtists() ([]model.Artist, error) {
-	resp, err := http.Get("https://groupietrackers.herokuapp.com/api/artists")
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var artists []model.Artist
-	if err := json.NewDecoder(resp.Body).Decode(&artists); err != nil {
-		return nil, err
-	}
-	return artists, nil
+	return Source.Artists()
 }
 
 func FetchLocations(id string) (model.Location, error) {
-	resp, err := http.Get("https://groupietrackers.herokuapp.com/api/locations")
-	if err != nil {
-		fmt.Println("Error reading the response body:", err)
-		return model.Location{}, err
-	}
-	defer resp.Body.Close()
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading the response body:", err)
-		return model.Location{}, err
-	}
-	// Unmarshal the JSON data into Go structs
-	var data model.AllLocations
-	err = json.Unmarshal(body, &data)
+	data, err := Source.Locations()
 	if err != nil {
-		fmt.Println("Error unmarshalling JSON:", err)
+		fmt.Println("Error fetching locations:", err)
 		return model.Location{}, err
 	}
 
-	Data.Locations = data.Location
 	var locations model.Location
 
-	for _, Artistid := range data.Location {
+	for _, Artistid := range data {
 		idNum, _ := strconv.Atoi(id)
 		if Artistid.ArtistId == idNum {
 			locations = Artistid
@@ -62,74 +31,40 @@ func FetchLocations(id string) (model.Location, error) {
 }
 
 func FetchDates(id string) (model.Date, error) {
-	resp, err := http.Get("https://groupietrackers.herokuapp.com/api/dates")
-	if err != nil {
-		fmt.Println("Error reading the response body:", err)
-		return model.Date{}, err
-	}
-	defer resp.Body.Close()
-
-	var data model.RootDates
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading the response body:", err)
-		return model.Date{}, err
-	}
-	// Unmarshal the JSON data into Go structs
-	err = json.Unmarshal(body, &data)
+	data, err := Source.Dates()
 	if err != nil {
-		fmt.Println("Error unmarshalling JSON:", err)
+		fmt.Println("Error fetching dates:", err)
 		return model.Date{}, err
 	}
 
 	var dates model.Date
 
-	Data.Dates = data.Tdates
-
-	for _, Artistid := range data.Tdates {
+	for _, Artistid := range data {
 		idNum := strconv.Itoa(Artistid.Id)
 		if idNum == id {
 			dates = Artistid
 		}
 	}
 
+	// copy before trimming so a MemorySource never sees its slices modified
+	dates.Dates = append([]string(nil), dates.Dates...)
 	for i, date := range dates.Dates {
-		if date[0] == '*' {
-			dates.Dates[i] = date[1:]
-		}
+		dates.Dates[i] = model.CleanDate(date)
 	}
 
 	return dates, nil
 }
 
 func FetchDatesAndConcerts(id string) (model.DatesLocations, error) {
-	resp, err := http.Get("https://groupietrackers.herokuapp.com/api/relation")
-	if err != nil {
-		fmt.Println("Error reading the response body:", err)
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var data model.RootsRelation
-
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading the response body:", err)
-		return nil, err
-	}
-
-	// Unmarshal the JSON data into Go structs
-	err = json.Unmarshal(body, &data)
+	data, err := Source.Relations()
 	if err != nil {
-		fmt.Println("Error unmarshalling JSON:", err)
+		fmt.Println("Error fetching relations:", err)
 		return nil, err
 	}
 
 	var datesLocations model.DatesLocations
 
-	for _, Artistid := range data.Relation {
+	for _, Artistid := range data {
 		idNum := strconv.Itoa(Artistid.Id)
 		if idNum == id {
 			datesLocations = Artistid.Places
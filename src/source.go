@@ -0,0 +1,225 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	model "tracker/models"
+)
+
+// DefaultBaseURL is the root of the public groupie tracker API.
+const DefaultBaseURL = "https://groupietrackers.herokuapp.com/api"
+
+// DataSource provides the four collections served by the groupie tracker API.
+type DataSource interface {
+	Artists() ([]model.Artist, error)
+	Locations() ([]model.Location, error)
+	Dates() ([]model.Date, error)
+	Relations() ([]model.DatesLocation, error)
+}
+
+// Source is the DataSource used by the fetch functions. main replaces it at
+// startup with the one selected by SourceFromEnv.
+var Source DataSource = NewHTTPSource(DefaultBaseURL)
+
+// SourceFromEnv builds the DataSource described by the environment:
+//
+//	DATA_SOURCE=http   (default) fetch from API_URL, or DefaultBaseURL
+//	DATA_SOURCE=dir    read artists.json, locations.json, dates.json and
+//	                   relation.json from DATA_DIR on every call
+//	DATA_SOURCE=memory read the same files from DATA_DIR once and serve
+//	                   them from memory
+func SourceFromEnv() (DataSource, error) {
+	dir := os.Getenv("DATA_DIR")
+	if dir == "" {
+		dir = "data"
+	}
+
+	switch kind := strings.ToLower(os.Getenv("DATA_SOURCE")); kind {
+	case "", "http":
+		baseURL := os.Getenv("API_URL")
+		if baseURL == "" {
+			baseURL = DefaultBaseURL
+		}
+		return NewHTTPSource(baseURL), nil
+	case "dir":
+		return NewDirSource(dir), nil
+	case "memory":
+		memory, err := CopyToMemory(NewDirSource(dir))
+		if err != nil {
+			return nil, err
+		}
+		return memory, nil
+	default:
+		return nil, fmt.Errorf("unknown DATA_SOURCE %q", kind)
+	}
+}
+
+// HTTPSource fetches every collection from a groupie tracker compatible API.
+type HTTPSource struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewHTTPSource(baseURL string) *HTTPSource {
+	return &HTTPSource{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSource) get(endpoint string, v any) error {
+	resp, err := s.Client.Get(s.BaseURL + "/" + endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s/%s: %s", s.BaseURL, endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+func (s *HTTPSource) Artists() ([]model.Artist, error) {
+	var artists []model.Artist
+	if err := s.get("artists", &artists); err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func (s *HTTPSource) Locations() ([]model.Location, error) {
+	var data model.AllLocations
+	if err := s.get("locations", &data); err != nil {
+		return nil, err
+	}
+	return data.Location, nil
+}
+
+func (s *HTTPSource) Dates() ([]model.Date, error) {
+	var data model.RootDates
+	if err := s.get("dates", &data); err != nil {
+		return nil, err
+	}
+	return data.Tdates, nil
+}
+
+func (s *HTTPSource) Relations() ([]model.DatesLocation, error) {
+	var data model.RootsRelation
+	if err := s.get("relation", &data); err != nil {
+		return nil, err
+	}
+	return data.Relation, nil
+}
+
+// DirSource reads the collections from a directory holding artists.json,
+// locations.json, dates.json and relation.json, laid out exactly as the API
+// returns them.
+type DirSource struct {
+	Dir string
+}
+
+func NewDirSource(dir string) *DirSource {
+	return &DirSource{Dir: dir}
+}
+
+func (s *DirSource) read(name string, v any) error {
+	f, err := os.Open(filepath.Join(s.Dir, name+".json"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", f.Name(), err)
+	}
+	return nil
+}
+
+func (s *DirSource) Artists() ([]model.Artist, error) {
+	var artists []model.Artist
+	if err := s.read("artists", &artists); err != nil {
+		return nil, err
+	}
+	return artists, nil
+}
+
+func (s *DirSource) Locations() ([]model.Location, error) {
+	var data model.AllLocations
+	if err := s.read("locations", &data); err != nil {
+		return nil, err
+	}
+	return data.Location, nil
+}
+
+func (s *DirSource) Dates() ([]model.Date, error) {
+	var data model.RootDates
+	if err := s.read("dates", &data); err != nil {
+		return nil, err
+	}
+	return data.Tdates, nil
+}
+
+func (s *DirSource) Relations() ([]model.DatesLocation, error) {
+	var data model.RootsRelation
+	if err := s.read("relation", &data); err != nil {
+		return nil, err
+	}
+	return data.Relation, nil
+}
+
+// MemorySource serves collections held in memory. It never fails.
+type MemorySource struct {
+	artists   []model.Artist
+	locations []model.Location
+	dates     []model.Date
+	relations []model.DatesLocation
+}
+
+func NewMemorySource(artists []model.Artist, locations []model.Location, dates []model.Date, relations []model.DatesLocation) *MemorySource {
+	return &MemorySource{
+		artists:   artists,
+		locations: locations,
+		dates:     dates,
+		relations: relations,
+	}
+}
+
+// CopyToMemory reads every collection from source once and returns a
+// MemorySource holding the result.
+func CopyToMemory(source DataSource) (*MemorySource, error) {
+	artists, err := source.Artists()
+	if err != nil {
+		return nil, err
+	}
+	locations, err := source.Locations()
+	if err != nil {
+		return nil, err
+	}
+	dates, err := source.Dates()
+	if err != nil {
+		return nil, err
+	}
+	relations, err := source.Relations()
+	if err != nil {
+		return nil, err
+	}
+	return NewMemorySource(artists, locations, dates, relations), nil
+}
+
+func (s *MemorySource) Artists() ([]model.Artist, error) { return s.artists, nil }
+
+func (s *MemorySource) Locations() ([]model.Location, error) { return s.locations, nil }
+
+func (s *MemorySource) Dates() ([]model.Date, error) { return s.dates, nil }
+
+func (s *MemorySource) Relations() ([]model.DatesLocation, error) { return s.relations, nil }
@@ -0,0 +1,118 @@
+package src
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testDataDir = "testdata/api"
+
+// TestMain points every test in the package at the bundled fixtures so the
+// suite runs without network access.
+func TestMain(m *testing.M) {
+	Source = NewDirSource(testDataDir)
+	os.Exit(m.Run())
+}
+
+// fixtureServer serves testdata/api the way the upstream API does, e.g.
+// /api/artists returns artists.json.
+func fixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Base(r.URL.Path) + ".json"
+		http.ServeFile(w, r, filepath.Join(testDataDir, name))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestDataSources checks that every implementation returns the same collections.
+func TestDataSources(t *testing.T) {
+	memory, err := CopyToMemory(NewDirSource(testDataDir))
+	if err != nil {
+		t.Fatalf("CopyToMemory() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		source DataSource
+	}{
+		{"http", NewHTTPSource(fixtureServer(t).URL + "/api/")},
+		{"dir", NewDirSource(testDataDir)},
+		{"memory", memory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			artists, err := tt.source.Artists()
+			if err != nil || len(artists) != 6 {
+				t.Errorf("Artists() = %d artists, error = %v", len(artists), err)
+			}
+			locations, err := tt.source.Locations()
+			if err != nil || len(locations) != 6 {
+				t.Errorf("Locations() = %d entries, error = %v", len(locations), err)
+			}
+			dates, err := tt.source.Dates()
+			if err != nil || len(dates) != 6 {
+				t.Errorf("Dates() = %d entries, error = %v", len(dates), err)
+			}
+			relations, err := tt.source.Relations()
+			if err != nil || len(relations) != 6 {
+				t.Errorf("Relations() = %d entries, error = %v", len(relations), err)
+			}
+			if len(relations) > 0 && len(relations[0].Places) == 0 {
+				t.Errorf("Relations() returned no places for ID %d", relations[0].Id)
+			}
+		})
+	}
+}
+
+func TestDataSourceErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		source DataSource
+	}{
+		{"http not found", NewHTTPSource(srv.URL)},
+		{"missing dir", NewDirSource(filepath.Join(t.TempDir(), "missing"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.source.Artists(); err == nil {
+				t.Errorf("Artists() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestSourceFromEnv(t *testing.T) {
+	tests := []struct {
+		kind    string
+		want    string
+		wantErr bool
+	}{
+		{"", "*src.HTTPSource", false},
+		{"http", "*src.HTTPSource", false},
+		{"dir", "*src.DirSource", false},
+		{"memory", "*src.MemorySource", false},
+		{"ftp", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			t.Setenv("DATA_SOURCE", tt.kind)
+			t.Setenv("DATA_DIR", testDataDir)
+			got, err := SourceFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SourceFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && fmt.Sprintf("%T", got) != tt.want {
+				t.Errorf("SourceFromEnv() = %T, want %s", got, tt.want)
+			}
+		})
+	}
+}
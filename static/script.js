@@ -31,54 +31,248 @@ document.addEventListener('DOMContentLoaded', function() {
         }, 150); // Reduced debounce time
     });
 
+    // Submitting the query opens the full results page
+    searchInput.addEventListener('keydown', function(e) {
+        const query = this.value.trim();
+        // the homepage filters its own list instead, through its form
+        if (e.key === 'Enter' && query.length > 0 && !searchInput.form) {
+            window.location.href = resultsUrl(query);
+        }
+    });
+
+    function resultsUrl(query) {
+        return `/results?q=${encodeURIComponent(query)}`;
+    }
+
+    // Tell /search/click which result was chosen for the query, without
+    // holding up the page that opens
+    function recordClick(query, type, id) {
+        const form = new URLSearchParams({ q: query, type });
+        if (id) {
+            form.set('id', id);
+        }
+        navigator.sendBeacon('/search/click', form);
+    }
+
+    // Homepage filters: each pair of year sliders fills the hidden field of
+    // its range, which stays empty while the range covers every artist
+    const filterForm = document.getElementById('filterForm');
+    if (filterForm) {
+        filterForm.querySelectorAll('.year-range').forEach(range => {
+            const from = range.querySelector('.range-from');
+            const to = range.querySelector('.range-to');
+            const label = range.querySelector('.range-label');
+            const field = range.querySelector('input[type=hidden]');
+
+            const update = (moved) => {
+                if (Number(from.value) > Number(to.value)) {
+                    (moved === from ? to : from).value = moved.value;
+                }
+                label.textContent = `${from.value} – ${to.value}`;
+                const all = from.value === from.min && to.value === to.max;
+                field.value = all ? '' : `${from.value}-${to.value}`;
+            };
+            from.addEventListener('input', () => update(from));
+            to.addEventListener('input', () => update(to));
+            label.textContent = `${from.value} – ${to.value}`;
+        });
+
+        const panel = document.getElementById('filtersPanel');
+        document.getElementById('toggleFilters').addEventListener('click', () => {
+            panel.style.display = panel.style.display === 'block' ? 'none' : 'block';
+        });
+        document.getElementById('resetFilters').addEventListener('click', () => {
+            window.location.href = '/';
+        });
+
+        // Submit a tidy URL: no empty fields, lists joined by commas
+        filterForm.addEventListener('submit', (e) => {
+            e.preventDefault();
+            const params = new URLSearchParams();
+            const form = new FormData(filterForm);
+            for (const key of ['q', 'created', 'album', 'sort']) {
+                const value = (form.get(key) || '').trim();
+                if (value) {
+                    params.set(key, value);
+                }
+            }
+            if (params.has('sort') && form.get('order') === 'desc') {
+                params.set('order', 'desc');
+            }
+            for (const key of ['members', 'location']) {
+                const values = form.getAll(key);
+                if (values.length) {
+                    params.set(key, values.join(','));
+                }
+            }
+            const query = params.toString();
+            window.location.href = query ? `/?${query}` : '/';
+        });
+    }
+
+    // Results listed by the results page
+    document.querySelectorAll('.result-item a[data-type]').forEach(link => {
+        link.addEventListener('click', () => {
+            recordClick(searchInput.value.trim(), link.dataset.type, link.dataset.id);
+        });
+    });
+
+    // Keystrokes are completed from /suggest. When nothing completes the
+    // text, a streamed search still offers typo matches and other spellings.
     async function fetchSuggestions(query) {
         try {
-            const response = await fetch(`/search?q=${encodeURIComponent(query)}`);
+            const response = await fetch(`/suggest?q=${encodeURIComponent(query)}`);
             const data = await response.json();
-            
-            if (data.success) {
-                displaySuggestions(data.results);
+
+            if (data.success && data.suggestions.length) {
+                if (stream) stream.close();
+                displaySuggestions(data.suggestions, null, query, []);
+                return;
             }
+            if (searchInput.value.trim() === query) fetchSearchResults(query);
         } catch (error) {
             console.error('Error fetching suggestions:', error);
         }
     }
 
-    function displaySuggestions(results) {
-        if (!results.length) {
+    // Search results arrive from /search/stream one category at a time; the
+    // dropdown is redrawn as each comes in, and once more with the summary
+    let stream;
+
+    function fetchSearchResults(query) {
+        if (stream) stream.close();
+        const source = new EventSource(`/search/stream?q=${encodeURIComponent(query)}`);
+        stream = source;
+        let results = [];
+
+        source.addEventListener('results', (e) => {
+            const event = JSON.parse(e.data);
+            results = results.concat(event.results);
+            displaySuggestions(results, results.length, query, []);
+        });
+        source.addEventListener('summary', (e) => {
+            const summary = JSON.parse(e.data);
+            source.close();
+            displaySuggestions(results, summary.total, query, summary.suggestions || []);
+        });
+        source.addEventListener('error', () => source.close());
+    }
+
+    // Map a relevance score onto the tiers used by /search:
+    // exact and prefix matches, word and substring matches, and typo matches
+    function scoreClass(score) {
+        if (score >= 75) return 'match-strong';
+        if (score >= 40) return 'match-partial';
+        return 'match-fuzzy';
+    }
+
+    // The highlights of one field of a result, as [start, end) character offsets
+    function rangesOf(highlights, field) {
+        return highlights.filter(h => h.field === field).map(h => [h.start, h.end]);
+    }
+
+    function textSpan(className, pieces) {
+        const span = document.createElement('span');
+        if (className) span.className = className;
+        span.textContent = pieces.join('');
+        return span;
+    }
+
+    // Build a span showing display, one string per character of the original
+    // field, with the characters in ranges wrapped in <mark>. Every piece is
+    // set through textContent, so API strings are never parsed as HTML.
+    function highlighted(className, original, display, ranges) {
+        const span = textSpan(className, []);
+        let last = 0;
+        ranges.forEach(([start, end]) => {
+            if (start < last || end > original.length || start >= end) return;
+            span.appendChild(document.createTextNode(display.slice(last, start).join('')));
+            const mark = document.createElement('mark');
+            mark.textContent = display.slice(start, end).join('');
+            span.appendChild(mark);
+            last = end;
+        });
+        span.appendChild(document.createTextNode(display.slice(last).join('')));
+        return span;
+    }
+
+    // Offer other spellings of a query that found little; picking one
+    // searches for it instead
+    function displayDidYouMean(spellings) {
+        spellings.forEach(spelling => {
+            const div = document.createElement('div');
+            div.className = 'suggestion-item did-you-mean';
+            div.appendChild(textSpan('suggestion-type', ['did you mean']));
+            div.appendChild(textSpan('', [spelling]));
+            div.addEventListener('click', () => {
+                searchInput.value = spelling;
+                fetchSuggestions(spelling);
+            });
+            suggestionsContainer.appendChild(div);
+        });
+    }
+
+    // The most search results the dropdown shows
+    const maxShown = 10;
+
+    // Show completions from /suggest, or results from /search with their
+    // total (null for completions) and other spellings of the query
+    function displaySuggestions(results, total, query, spellings) {
+        if (!results.length && !spellings.length) {
             suggestionsContainer.style.display = 'none';
             return;
         }
     
         suggestionsContainer.innerHTML = '';
+        displayDidYouMean(spellings);
+
+        // The server already ranks results; keep that order if scores tie
+        if (total !== null) {
+            results = results
+                .map((result, i) => ({ result, i }))
+                .sort((a, b) => (b.result.score - a.result.score) || (a.i - b.i))
+                .map(({ result }) => result)
+                .slice(0, maxShown);
+        }
         
         results.forEach(result => {
             const div = document.createElement('div');
-            div.className = 'suggestion-item';
-            // Format location before displaying
-            function formatLocation(location) {
-                return location
-                    .replace(/[_]/g, ' ')  
-                    .replace(/[-]/g, ' , ')              // Replace underscores and hyphens with space
-                    .split(' ')                           // Split into words
-                    .map(word => word.charAt(0).toUpperCase() + word.slice(1).toLowerCase()) // Capitalize first letter of each word
-                    .join(' ');                          // Join words back into a single string
+            if (total === null) {
+                div.className = 'suggestion-item';
+                div.title = `${result.popularity} concerts`;
+            } else {
+                div.className = `suggestion-item ${scoreClass(result.score)}`;
+                div.title = `relevance ${result.score}`;
             }
-            
+            const highlights = result.highlights || [];
+
             // Display both the main text and context if available
-            div.innerHTML = `
-                <span class="suggestion-type">${result.type}</span>
-                ${result.context ? `<span class="suggestion-type">${result.context}</span>` : ''}
-                <span>${formatLocation(result.text)}</span>
-                
-            `;
-            
+            div.appendChild(textSpan('suggestion-type', [result.type]));
+            if (result.context) {
+                const context = Array.from(result.context);
+                div.appendChild(highlighted('suggestion-type', context, context, rangesOf(highlights, 'context')));
+            }
+            const text = Array.from(result.text);
+            div.appendChild(highlighted('', text, text, rangesOf(highlights, 'text')));
+
+            // Locations belong to no single artist: list everything played there
             div.addEventListener('click', () => {
-                    window.location.href = `/artist?id=${result.id}`
+                    recordClick(query, result.type, result.id);
+                    window.location.href = result.id ? `/artist?id=${result.id}` : resultsUrl(result.text)
             });
     
             suggestionsContainer.appendChild(div);
         });
+
+        if (total === null || total > results.length) {
+            const more = document.createElement('div');
+            more.className = 'see-all';
+            more.textContent = total === null ? `See all results for "${query}"` : `See all ${total} results`;
+            more.addEventListener('click', () => {
+                window.location.href = resultsUrl(query);
+            });
+            suggestionsContainer.appendChild(more);
+        }
         
         suggestionsContainer.style.display = 'block';
     }